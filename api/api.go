@@ -2,20 +2,38 @@ package api
 
 import (
 	"dimi/kkalcs/logger"
+	"dimi/kkalcs/mlapi"
 	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/period"
+	"dimi/kkalcs/store"
 	"encoding/json"
 	"log/slog"
 	"net/http"
-	"strconv"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
 )
 
-func Run() error {
+type server struct {
+	client *mlapi.Client
+	// db, se definido, é usado no lugar das chamadas ao Mercado Livre.
+	db *store.DB
+	// notifier atualiza db a partir das notificações. É nil quando não há db.
+	notifier *notifier
+}
+
+func Run(c *mlapi.Client, db *store.DB) error {
+	s := &server{client: c, db: db}
+	if db != nil {
+		s.notifier = newNotifier(c, db)
+	}
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("GET /api/v1/orders", getOrders)
+	mux.HandleFunc("GET /api/v1/orders", s.getOrders)
+	mux.HandleFunc("GET /api/v1/orders/export", s.exportOrders)
+	mux.HandleFunc("GET /api/v1/orders/compare", s.compareOrders)
+	mux.HandleFunc("POST /api/v1/notifications", s.receiveNotification)
 
 	server := &http.Server{
 		Addr:    ":8080",
@@ -26,48 +44,15 @@ func Run() error {
 	return err
 }
 
-func getOrders(w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-
-	year1Str := query.Get("year1")
-	month1Str := query.Get("month1")
-	year2Str := query.Get("year2")
-	month2Str := query.Get("month2")
-
-	if year1Str == "" || month1Str == "" || year2Str == "" || month2Str == "" {
-		http.Error(w, "Missing date parameters. Required: year1, month1, year2, month2", http.StatusBadRequest)
-		return
-	}
-	year1, err := strconv.Atoi(year1Str)
-	if err != nil {
-		http.Error(w, "Invalid year1 parameter", http.StatusBadRequest)
-		return
-	}
-	month1, err := strconv.Atoi(month1Str)
-	if err != nil || month1 < 1 || month1 > 12 {
-		http.Error(w, "Invalid month1 parameter", http.StatusBadRequest)
-		return
-	}
-	year2, err := strconv.Atoi(year2Str)
+func (s *server) getOrders(w http.ResponseWriter, r *http.Request) {
+	p, err := period.Parse(r.URL.Query())
 	if err != nil {
-		http.Error(w, "Invalid year2 parameter", http.StatusBadRequest)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
-	month2, err := strconv.Atoi(month2Str)
-	if err != nil || month2 < 1 || month2 > 12 {
-		http.Error(w, "Invalid month2 parameter", http.StatusBadRequest)
-		return
-	}
-	if year1 > year2 || (year1 == year2 && month1 > month2) {
-		http.Error(w, "Invalid date range", http.StatusBadRequest)
-		return
-	}
-
-	dateFrom := time.Date(year1, time.Month(month1), 21, 0, 0, 0, 0, time.UTC)
-	dateTo := time.Date(year2, time.Month(month2), 22, 0, 0, 0, 0, time.UTC).Add(-1 * time.Nanosecond)
-	slog.Info("Fetching orders", "dateFrom", dateFrom, "dateTo", dateTo)
+	slog.Info("Fetching orders", "dateFrom", p.From, "dateTo", p.To)
 
-	data, err := orders.FetchAll(dateFrom, dateTo)
+	data, err := s.fetchOrders(p.From, p.To)
 	if err != nil {
 		slog.Error("Failed to fetch orders", "error", err)
 		http.Error(w, "Internal error", http.StatusInternalServerError)
@@ -84,6 +69,20 @@ func getOrders(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte(jsonResult))
 }
 
+// fetchOrders busca os pedidos no banco local, se houver, ou no Mercado Livre.
+func (s *server) fetchOrders(dateFrom, dateTo time.Time) ([]orders.Order, error) {
+	filter := orders.DefaultFilter()
+	if s.db == nil {
+		return orders.FetchAll(s.client, dateFrom, dateTo, filter)
+	}
+
+	ords, err := s.db.OrdersBetween(dateFrom, dateTo)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(ords, func(o orders.Order) bool { return !filter.Match(o) }), nil
+}
+
 func loggerMdwr(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestID := r.Header.Get("X-Request-ID")
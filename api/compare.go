@@ -0,0 +1,78 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"dimi/kkalcs/cogs"
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/period"
+)
+
+// comparison é a resposta de compareOrders. Margins compara o CMV e as margens
+// com cada período de Against, na mesma ordem, e só existe com o banco local,
+// onde ficam os custos unitários.
+type comparison struct {
+	orders.ComparisonReport
+	Margins []cogs.Comparison `json:"margins,omitempty"`
+}
+
+// compareOrders compara o resumo do período com outros períodos.
+// Parâmetros: os mesmos de getOrders, mais against (previous, last_year ou
+// os dois separados por vírgula, o padrão).
+func (s *server) compareOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	p, err := period.Parse(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	against, err := period.Against(p, query.Get("against"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	current, err := s.fetchOrders(p.From, p.To)
+	if err != nil {
+		slog.Error("Failed to fetch orders", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	report := comparison{ComparisonReport: orders.ComparisonReport{Period: p.String(), Summary: orders.Total(current)}}
+
+	var costs *cogs.Table
+	if s.db != nil {
+		costs, err = s.db.UnitCosts()
+		if err != nil {
+			slog.Error("Failed to load unit costs", "error", err)
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	for _, a := range against {
+		ords, err := s.fetchOrders(a.From, a.To)
+		if err != nil {
+			slog.Error("Failed to fetch orders", "error", err)
+			http.Error(w, "Internal error", http.StatusInternalServerError)
+			return
+		}
+		report.Add(a.String(), orders.Total(ords))
+		if costs != nil {
+			report.Margins = append(report.Margins, cogs.Compare(a.String(),
+				cogs.Margins(current, costs, orders.Monthly), cogs.Margins(ords, costs, orders.Monthly)))
+		}
+	}
+
+	jsonResult, err := json.Marshal(report)
+	if err != nil {
+		slog.Error("Failed to marshal comparison", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(jsonResult)
+}
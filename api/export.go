@@ -0,0 +1,167 @@
+package api
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"dimi/kkalcs/export"
+	"dimi/kkalcs/fanout"
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/shipments"
+	"dimi/kkalcs/period"
+	"dimi/kkalcs/profit"
+	"dimi/kkalcs/taxes"
+)
+
+// exportOrders devolve um relatório do período em CSV ou XLSX.
+// Parâmetros: os mesmos de getOrders, mais kind (orders, items, shipments,
+// summary, profit), format (csv, xlsx) e locale (br para ";" e vírgula decimal).
+func (s *server) exportOrders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+
+	p, err := period.Parse(query)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	kindStr := query.Get("kind")
+	if kindStr == "" {
+		kindStr = string(export.KindOrders)
+	}
+	kind, err := export.ParseKind(kindStr)
+	if err != nil {
+		http.Error(w, "Invalid kind parameter", http.StatusBadRequest)
+		return
+	}
+
+	locale, err := export.ParseLocale(query.Get("locale"))
+	if err != nil {
+		http.Error(w, "Invalid locale parameter", http.StatusBadRequest)
+		return
+	}
+
+	format := query.Get("format")
+	if format == "" {
+		format = "csv"
+	}
+	if format != "csv" && format != "xlsx" {
+		http.Error(w, "Invalid format parameter. Use csv or xlsx", http.StatusBadRequest)
+		return
+	}
+
+	// Custos unitários, anúncios e reclamações só existem no banco local; sem
+	// ele o lucro sairia sem CMV e não bateria com o CalculateProfit.
+	if kind == export.KindProfit && s.db == nil {
+		http.Error(w, "The profit export needs the local database", http.StatusServiceUnavailable)
+		return
+	}
+
+	data, err := s.exportData(kind, p.From, p.To)
+	if err != nil {
+		slog.Error("Failed to load export data", "error", err)
+		http.Error(w, "Internal error", http.StatusInternalServerError)
+		return
+	}
+	table := kind.Table(*data)
+
+	filename := fmt.Sprintf("%s_%s.%s", kind, strings.ReplaceAll(p.String(), "..", "_"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "xlsx" {
+		w.Header().Set("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		err = export.WriteXLSX(w, table)
+	} else {
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		err = export.WriteCSV(w, table, locale)
+	}
+	if err != nil {
+		slog.Error("Failed to write export", "error", err)
+	}
+}
+
+func (s *server) exportData(kind export.Kind, dateFrom, dateTo time.Time) (*export.Data, error) {
+	ords, err := s.fetchOrders(dateFrom, dateTo)
+	if err != nil {
+		return nil, err
+	}
+	data := &export.Data{Orders: ords}
+	if !kind.NeedsShipments() {
+		return data, nil
+	}
+
+	data.ShipmentCosts, err = s.shipmentCosts(ords)
+	if err != nil {
+		return nil, err
+	}
+
+	if kind == export.KindProfit {
+		rules, err := taxes.LoadIfExists(taxes.RulesFile)
+		if err != nil {
+			return nil, err
+		}
+		in, err := profit.LoadInputs(s.db, ords, dateFrom, dateTo, rules)
+		if err != nil {
+			return nil, err
+		}
+		data.Ledger = profit.Build(in)
+	}
+
+	return data, nil
+}
+
+// shipmentCosts busca os custos de envio dos packs, usando o banco quando houver
+// e o Mercado Livre para o que faltar ou estiver desatualizado.
+func (s *server) shipmentCosts(ords []orders.Order) ([]shipments.ShipmentCost, error) {
+	var ids []string
+	soldAt := make(map[string]time.Time)
+	for _, pack := range orders.GroupPacks(ords) {
+		if pack.ShippingID != 0 {
+			id := strconv.Itoa(pack.ShippingID)
+			ids = append(ids, id)
+			soldAt[id] = pack.SoldAt()
+		}
+	}
+
+	cached := make(map[string]shipments.ShipmentCost)
+	if s.db != nil {
+		var err error
+		cached, err = s.db.ShipmentCosts(ids)
+		if err != nil {
+			return nil, err
+		}
+	}
+	missing := shipments.Missing(ids, cached, soldAt)
+
+	opts := fanout.Options{Workers: 8, Interval: 50 * time.Millisecond}
+	fetched, errs := fanout.Run(missing, opts, func(id string) (*shipments.ShipmentCost, error) {
+		return shipments.FetchCosts(s.client, id)
+	})
+	for id, err := range errs {
+		slog.Warn("Failed to fetch shipment costs", "shipment_id", id, "error", err)
+	}
+	var newCosts []shipments.ShipmentCost
+	for _, c := range fetched {
+		newCosts = append(newCosts, *c)
+	}
+	if s.db != nil {
+		err := s.db.UpsertShipmentCosts(newCosts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Se a nova busca falhou, o custo salvo continua valendo.
+	for _, c := range newCosts {
+		cached[c.ShipmentID] = c
+	}
+	costs := make([]shipments.ShipmentCost, 0, len(cached))
+	for _, c := range cached {
+		costs = append(costs, c)
+	}
+
+	return costs, nil
+}
@@ -0,0 +1,208 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"path"
+	"strconv"
+	"sync"
+
+	"dimi/kkalcs/mlapi"
+	"dimi/kkalcs/mlapi/claims"
+	"dimi/kkalcs/mlapi/items"
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/questions"
+	"dimi/kkalcs/mlapi/shipments"
+	"dimi/kkalcs/store"
+)
+
+// queueSize é quantas notificações podem esperar pelo worker. Com a fila
+// cheia o endpoint responde 503 e o Mercado Livre reenvia mais tarde.
+const queueSize = 1000
+
+// notification é o corpo enviado pelo Mercado Livre. Resource é o caminho do
+// recurso alterado, como "/orders/2000010876085454".
+type notification struct {
+	ID       string `json:"_id"`
+	Resource string `json:"resource"`
+	UserID   int64  `json:"user_id"`
+	Topic    string `json:"topic"`
+	Attempts int    `json:"attempts"`
+}
+
+func (n notification) key() string {
+	return n.Topic + n.Resource
+}
+
+// notifier enfileira os recursos notificados e os busca de novo, um por vez,
+// atualizando o banco local. Um recurso que já está na fila não é enfileirado
+// de novo: a busca pendente já trará a versão mais recente.
+type notifier struct {
+	client *mlapi.Client
+	db     *store.DB
+	queue  chan notification
+
+	mu      sync.Mutex
+	pending map[string]bool
+}
+
+func newNotifier(c *mlapi.Client, db *store.DB) *notifier {
+	n := &notifier{
+		client:  c,
+		db:      db,
+		queue:   make(chan notification, queueSize),
+		pending: make(map[string]bool),
+	}
+	go n.work()
+	return n
+}
+
+// enqueue coloca a notificação na fila. Retorna false se a fila estiver cheia.
+func (n *notifier) enqueue(note notification) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.pending[note.key()] {
+		return true
+	}
+	select {
+	case n.queue <- note:
+		n.pending[note.key()] = true
+		return true
+	default:
+		return false
+	}
+}
+
+func (n *notifier) work() {
+	for note := range n.queue {
+		// Liberado antes da busca, para que uma alteração feita durante ela
+		// seja enfileirada de novo.
+		n.mu.Lock()
+		delete(n.pending, note.key())
+		n.mu.Unlock()
+
+		err := n.refetch(note)
+		if err != nil {
+			slog.Error("Failed to process notification", "topic", note.Topic, "resource", note.Resource, "error", err)
+			continue
+		}
+		slog.Info("Notification processed", "topic", note.Topic, "resource", note.Resource)
+	}
+}
+
+// refetch busca o recurso notificado e atualiza o banco local.
+func (n *notifier) refetch(note notification) error {
+	id := path.Base(note.Resource)
+
+	switch note.Topic {
+	case "orders_v2":
+		return n.refetchOrder(id)
+	case "shipments":
+		cost, err := shipments.FetchCosts(n.client, id)
+		if err != nil {
+			return err
+		}
+		orderID, err := shipments.Fetch(n.client, id)
+		if err != nil {
+			return err
+		}
+		if err := n.db.UpsertShipment(id, int64(orderID)); err != nil {
+			return err
+		}
+		return n.db.UpsertShipmentCosts([]shipments.ShipmentCost{*cost})
+	case "items":
+		found, errs := items.GetMap(n.client, []string{id})
+		if err, ok := errs[id]; ok {
+			return err
+		}
+		its := make([]items.Item, 0, len(found))
+		for _, it := range found {
+			its = append(its, it)
+		}
+		return n.db.UpsertItems(its)
+	case "claims":
+		claimID, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return fmt.Errorf("ID de reclamação inválido: %s", id)
+		}
+		claim, err := claims.Get(n.client, claimID)
+		if err != nil {
+			return err
+		}
+		claim.Return, err = claims.FetchReturn(n.client, claimID)
+		if err != nil {
+			return err
+		}
+		if err := n.db.UpsertClaims([]claims.Claim{*claim}); err != nil {
+			return err
+		}
+		// O pedido também é atualizado para refletir reembolsos e cancelamentos.
+		if claim.OrderID() == 0 {
+			return nil
+		}
+		return n.refetchOrder(strconv.FormatInt(claim.OrderID(), 10))
+	case "questions":
+		question, err := questions.Get(n.client, id)
+		if err != nil {
+			return err
+		}
+		return n.db.UpsertQuestions([]questions.Question{*question})
+	default:
+		return fmt.Errorf("tópico não suportado: %s", note.Topic)
+	}
+}
+
+func (n *notifier) refetchOrder(id string) error {
+	order, err := orders.Get(n.client, id)
+	if err != nil {
+		return err
+	}
+	ords := []orders.Order{*order}
+	for id, err := range orders.AttachDiscounts(n.client, ords) {
+		slog.Warn("Failed to fetch order discounts", "order_id", id, "error", err)
+	}
+	return n.db.UpsertOrders(ords)
+}
+
+// receiveNotification recebe as notificações do Mercado Livre. O Mercado Livre
+// espera a resposta em até 500 ms, então o recurso só é enfileirado aqui e
+// buscado depois pelo notifier.
+func (s *server) receiveNotification(w http.ResponseWriter, r *http.Request) {
+	var note notification
+	err := json.NewDecoder(r.Body).Decode(&note)
+	if err != nil || note.Topic == "" || note.Resource == "" {
+		http.Error(w, "Invalid notification body", http.StatusBadRequest)
+		return
+	}
+
+	switch note.Topic {
+	case "orders_v2", "shipments", "items", "questions", "claims":
+	default:
+		// Tópicos assinados por engano são confirmados para não serem reenviados.
+		slog.Warn("Ignoring notification topic", "topic", note.Topic, "resource", note.Resource)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if seller, err := s.client.Seller(); err == nil && strconv.FormatInt(note.UserID, 10) != seller {
+		slog.Warn("Ignoring notification for another user", "user_id", note.UserID, "topic", note.Topic)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if s.notifier == nil {
+		slog.Warn("Notification received without a local database", "topic", note.Topic, "resource", note.Resource)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+
+	if !s.notifier.enqueue(note) {
+		slog.Error("Notification queue is full", "topic", note.Topic, "resource", note.Resource)
+		http.Error(w, "Queue full", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
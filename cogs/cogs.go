@@ -0,0 +1,204 @@
+package cogs
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dimi/kkalcs/timezone"
+)
+
+const dateLayout = "2006-01-02"
+
+// UnitCost é o custo unitário de um SKU a partir de uma data. Um SKU pode ter
+// vários custos; vale o mais recente que já esteja em vigor na data da venda.
+type UnitCost struct {
+	SKU           string    `json:"sku"`
+	Cost          float64   `json:"unit_cost"`
+	EffectiveFrom time.Time `json:"effective_from"`
+}
+
+// Table indexa os custos unitários por SKU.
+type Table struct {
+	bySKU map[string][]UnitCost
+}
+
+func NewTable(costs []UnitCost) *Table {
+	t := &Table{bySKU: make(map[string][]UnitCost)}
+	for _, c := range costs {
+		t.bySKU[c.SKU] = append(t.bySKU[c.SKU], c)
+	}
+	for sku := range t.bySKU {
+		sort.Slice(t.bySKU[sku], func(i, j int) bool {
+			return t.bySKU[sku][i].EffectiveFrom.Before(t.bySKU[sku][j].EffectiveFrom)
+		})
+	}
+	return t
+}
+
+// UnitCost retorna o custo do SKU em vigor na data. ok é falso se o SKU não tem
+// custo cadastrado ou se todos os custos começam depois da data.
+func (t *Table) UnitCost(sku string, at time.Time) (cost float64, ok bool) {
+	costs := t.bySKU[sku]
+	for i := len(costs) - 1; i >= 0; i-- {
+		if !costs[i].EffectiveFrom.After(at) {
+			return costs[i].Cost, true
+		}
+	}
+	return 0, false
+}
+
+// Costs retorna todos os custos da tabela.
+func (t *Table) Costs() []UnitCost {
+	var all []UnitCost
+	for _, costs := range t.bySKU {
+		all = append(all, costs...)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].SKU != all[j].SKU {
+			return all[i].SKU < all[j].SKU
+		}
+		return all[i].EffectiveFrom.Before(all[j].EffectiveFrom)
+	})
+	return all
+}
+
+// Load carrega os custos de um arquivo .csv ou .json.
+func Load(path string) (*Table, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return LoadCSV(path)
+	case ".json":
+		return LoadJSON(path)
+	default:
+		return nil, fmt.Errorf("formato de arquivo de custos não suportado: %s", path)
+	}
+}
+
+// LoadJSON lê uma lista de UnitCost, com effective_from no formato AAAA-MM-DD.
+func LoadJSON(path string) (*Table, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o arquivo: %v", err)
+	}
+
+	var raw []struct {
+		SKU           string  `json:"sku"`
+		Cost          float64 `json:"unit_cost"`
+		EffectiveFrom string  `json:"effective_from"`
+	}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao deserializar custos: %v", err)
+	}
+
+	costs := make([]UnitCost, 0, len(raw))
+	for _, r := range raw {
+		from, err := parseDate(r.EffectiveFrom)
+		if err != nil {
+			return nil, fmt.Errorf("data inválida para o SKU %s: %v", r.SKU, err)
+		}
+		costs = append(costs, UnitCost{SKU: r.SKU, Cost: r.Cost, EffectiveFrom: from})
+	}
+
+	return NewTable(costs), nil
+}
+
+// LoadCSV lê um CSV com as colunas sku, unit_cost e effective_from (opcional).
+// Aceita tanto "," quanto ";" como separador; com ";" a vírgula é tratada como
+// separador decimal, como nas planilhas em português, e valores sem vírgula
+// são lidos com ponto decimal.
+func LoadCSV(path string) (*Table, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o arquivo: %v", err)
+	}
+	defer f.Close()
+
+	return ReadCSV(f)
+}
+
+func ReadCSV(r io.Reader) (*Table, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header, _, _ := strings.Cut(string(data), "\n")
+	reader := csv.NewReader(strings.NewReader(string(data)))
+	comma := strings.Contains(header, ";")
+	if comma {
+		reader.Comma = ';'
+	}
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler CSV: %v", err)
+	}
+	if len(records) == 0 {
+		return NewTable(nil), nil
+	}
+
+	cols := make(map[string]int)
+	for i, name := range records[0] {
+		cols[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	skuCol, okSKU := cols["sku"]
+	costCol, okCost := cols["unit_cost"]
+	if !okSKU || !okCost {
+		return nil, fmt.Errorf("CSV de custos precisa das colunas sku e unit_cost")
+	}
+	fromCol, hasFrom := cols["effective_from"]
+
+	var costs []UnitCost
+	for line, rec := range records[1:] {
+		cost, err := parseCost(rec[costCol], comma)
+		if err != nil {
+			return nil, fmt.Errorf("custo inválido na linha %d: %v", line+2, err)
+		}
+
+		var from time.Time
+		if hasFrom {
+			from, err = parseDate(rec[fromCol])
+			if err != nil {
+				return nil, fmt.Errorf("data inválida na linha %d: %v", line+2, err)
+			}
+		}
+
+		costs = append(costs, UnitCost{SKU: strings.TrimSpace(rec[skuCol]), Cost: cost, EffectiveFrom: from})
+	}
+
+	return NewTable(costs), nil
+}
+
+// parseCost lê o custo. Com decimalComma, "1.234,56" e "12,50" usam a vírgula
+// como decimal e o ponto como milhar; valores sem vírgula, como "12.50", são
+// lidos como estão.
+func parseCost(s string, decimalComma bool) (float64, error) {
+	value := strings.TrimSpace(s)
+	if decimalComma && strings.Contains(value, ",") {
+		value = strings.ReplaceAll(strings.ReplaceAll(value, ".", ""), ",", ".")
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+// parseDate aceita AAAA-MM-DD ou DD/MM/AAAA, no fuso configurado. Data vazia
+// vale desde sempre.
+func parseDate(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(dateLayout, s, timezone.Location()); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("02/01/2006", s, timezone.Location())
+}
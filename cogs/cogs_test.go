@@ -0,0 +1,150 @@
+package cogs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"dimi/kkalcs/timezone"
+)
+
+func TestParseCost(t *testing.T) {
+	tests := []struct {
+		in           string
+		decimalComma bool
+		want         float64
+		wantErr      bool
+	}{
+		{"12.50", false, 12.5, false},
+		{" 12.50 ", false, 12.5, false},
+		{"1234", false, 1234, false},
+		{"12,50", true, 12.5, false},
+		{"1.234,56", true, 1234.56, false},
+		{"1.234.567,8", true, 1234567.8, false},
+		{"12.50", true, 12.5, false},
+		{"1234", true, 1234, false},
+		{"12,50", false, 0, true},
+		{"abc", true, 0, true},
+	}
+	for _, tt := range tests {
+		got, err := parseCost(tt.in, tt.decimalComma)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("parseCost(%q, %v) = %v, want an error", tt.in, tt.decimalComma, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("parseCost(%q, %v) = %v, %v, want %v", tt.in, tt.decimalComma, got, err, tt.want)
+		}
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	march := timezone.Date(2025, time.March, 1, 0, 0, 0, 0)
+
+	tests := []struct {
+		name    string
+		csv     string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{
+			name: "comma separated",
+			csv:  "sku,unit_cost\nABC,12.50\nDEF,3\n",
+			want: map[string]float64{"ABC": 12.5, "DEF": 3},
+		},
+		{
+			name: "semicolon with decimal comma",
+			csv:  "sku;unit_cost\nABC;12,50\nDEF;1.234,56\n",
+			want: map[string]float64{"ABC": 12.5, "DEF": 1234.56},
+		},
+		{
+			name: "semicolon with decimal point",
+			csv:  "sku;unit_cost\nABC;12.50\nDEF;7\n",
+			want: map[string]float64{"ABC": 12.5, "DEF": 7},
+		},
+		{
+			name: "quoted value with thousands separator",
+			csv:  "sku;unit_cost\n\"ABC\";\"1.050,00\"\n",
+			want: map[string]float64{"ABC": 1050},
+		},
+		{
+			name: "header case and spaces",
+			csv:  " SKU ; Unit_Cost \n ABC ; 2,5\n",
+			want: map[string]float64{"ABC": 2.5},
+		},
+		{
+			name: "effective dates in both formats",
+			csv:  "sku;unit_cost;effective_from\nABC;10,00;2025-01-01\nDEF;20,00;15/02/2025\n",
+			want: map[string]float64{"ABC": 10, "DEF": 20},
+		},
+		{
+			name: "empty file",
+			csv:  "",
+			want: map[string]float64{},
+		},
+		{
+			name:    "missing unit_cost column",
+			csv:     "sku;cost\nABC;10\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid cost",
+			csv:     "sku,unit_cost\nABC,ten\n",
+			wantErr: true,
+		},
+		{
+			name:    "invalid date",
+			csv:     "sku,unit_cost,effective_from\nABC,10,2025-13-01\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table, err := ReadCSV(strings.NewReader(tt.csv))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := len(table.Costs()); got != len(tt.want) {
+				t.Errorf("got %d costs, want %d", got, len(tt.want))
+			}
+			for sku, want := range tt.want {
+				got, ok := table.UnitCost(sku, march)
+				if !ok || got != want {
+					t.Errorf("UnitCost(%s) = %v, %v, want %v", sku, got, ok, want)
+				}
+			}
+		})
+	}
+}
+
+func TestUnitCostEffectiveFrom(t *testing.T) {
+	table, err := ReadCSV(strings.NewReader("sku;unit_cost;effective_from\nABC;10,00;\nABC;12,00;2025-03-01\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		at   time.Time
+		want float64
+	}{
+		{timezone.Date(2025, time.February, 28, 23, 59, 59, 0), 10},
+		{timezone.Date(2025, time.March, 1, 0, 0, 0, 0), 12},
+		{timezone.Date(2026, time.January, 1, 0, 0, 0, 0), 12},
+	}
+	for _, tt := range tests {
+		if got, ok := table.UnitCost("ABC", tt.at); !ok || got != tt.want {
+			t.Errorf("UnitCost at %s = %v, %v, want %v", tt.at, got, ok, tt.want)
+		}
+	}
+	if _, ok := table.UnitCost("XYZ", tests[1].at); ok {
+		t.Error("unknown SKU should have no cost")
+	}
+}
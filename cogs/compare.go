@@ -0,0 +1,53 @@
+package cogs
+
+import "dimi/kkalcs/mlapi/orders"
+
+// MarginDelta compara duas margens. As taxas são sobre a receita.
+type MarginDelta struct {
+	COGS             orders.Delta `json:"cogs"`
+	GrossMargin      orders.Delta `json:"gross_margin"`
+	GrossMarginRate  orders.Delta `json:"gross_margin_rate"`
+	Contribution     orders.Delta `json:"contribution"`
+	ContributionRate orders.Delta `json:"contribution_rate"`
+	// MissingCost indica que faltou custo em algum dos dois períodos.
+	MissingCost bool `json:"missing_cost"`
+}
+
+func compareMargin(current, previous Margin) MarginDelta {
+	return MarginDelta{
+		COGS:             orders.NewDelta(current.COGS, previous.COGS),
+		GrossMargin:      orders.NewDelta(current.GrossMargin, previous.GrossMargin),
+		GrossMarginRate:  orders.NewDelta(current.GrossMarginRate(), previous.GrossMarginRate()),
+		Contribution:     orders.NewDelta(current.Contribution, previous.Contribution),
+		ContributionRate: orders.NewDelta(current.ContributionRate(), previous.ContributionRate()),
+		MissingCost:      current.MissingCost || previous.MissingCost,
+	}
+}
+
+// Comparison é a variação das margens entre dois períodos, complementando a
+// orders.Comparison com o custo da mercadoria. SKUs que só aparecem em um dos
+// períodos entram com zero no outro.
+type Comparison struct {
+	Period string                 `json:"period"`
+	Total  MarginDelta            `json:"total"`
+	BySKU  map[string]MarginDelta `json:"by_sku"`
+}
+
+// Compare calcula a variação das margens de current em relação a previous, o
+// relatório do período informado.
+func Compare(period string, current, previous Report) Comparison {
+	c := Comparison{
+		Period: period,
+		Total:  compareMargin(current.Total, previous.Total),
+		BySKU:  make(map[string]MarginDelta, len(current.BySKU)),
+	}
+	for sku, m := range current.BySKU {
+		c.BySKU[sku] = compareMargin(m, previous.BySKU[sku])
+	}
+	for sku, m := range previous.BySKU {
+		if _, ok := current.BySKU[sku]; !ok {
+			c.BySKU[sku] = compareMargin(Margin{}, m)
+		}
+	}
+	return c
+}
@@ -0,0 +1,129 @@
+package cogs
+
+import (
+	"sort"
+
+	"dimi/kkalcs/mlapi/orders"
+)
+
+// Margin é o resultado de uma venda depois do custo da mercadoria.
+// GrossMargin = receita - CMV. Contribution = receita - tarifa de venda -
+// descontos bancados pelo vendedor - CMV.
+type Margin struct {
+	Units          int     `json:"units"`
+	Revenue        float64 `json:"revenue"`
+	SaleFee        float64 `json:"sale_fee"`
+	SellerDiscount float64 `json:"seller_discount"`
+	COGS           float64 `json:"cogs"`
+	GrossMargin    float64 `json:"gross_margin"`
+	Contribution   float64 `json:"contribution"`
+	// MissingCost indica que algum item não tinha custo, e o CMV está subestimado.
+	MissingCost bool `json:"missing_cost"`
+}
+
+func (m *Margin) add(o Margin) {
+	m.Units += o.Units
+	m.Revenue += o.Revenue
+	m.SaleFee += o.SaleFee
+	m.SellerDiscount += o.SellerDiscount
+	m.COGS += o.COGS
+	m.GrossMargin += o.GrossMargin
+	m.Contribution += o.Contribution
+	m.MissingCost = m.MissingCost || o.MissingCost
+}
+
+// GrossMarginRate é a margem bruta sobre a receita.
+func (m Margin) GrossMarginRate() float64 {
+	if m.Revenue == 0 {
+		return 0
+	}
+	return m.GrossMargin / m.Revenue
+}
+
+// ContributionRate é a margem de contribuição sobre a receita.
+func (m Margin) ContributionRate() float64 {
+	if m.Revenue == 0 {
+		return 0
+	}
+	return m.Contribution / m.Revenue
+}
+
+type ItemMargin struct {
+	OrderID int64  `json:"order_id"`
+	ItemID  string `json:"item_id"`
+	SKU     string `json:"seller_sku"`
+	Margin
+}
+
+type Report struct {
+	Items    []ItemMargin      `json:"items"`
+	ByOrder  map[int64]Margin  `json:"by_order"`
+	BySKU    map[string]Margin `json:"by_sku"`
+	ByPeriod map[string]Margin `json:"by_period"`
+	Total    Margin            `json:"total"`
+	// MissingSKUs são os SKUs vendidos sem custo em vigor na data da venda.
+	MissingSKUs []string `json:"missing_skus"`
+}
+
+// Margins calcula a margem de cada item, pedido e período usando o custo do SKU
+// em vigor na data de criação do pedido.
+func Margins(ords []orders.Order, t *Table, period func(orders.Order) string) Report {
+	report := Report{
+		ByOrder:  make(map[int64]Margin),
+		BySKU:    make(map[string]Margin),
+		ByPeriod: make(map[string]Margin),
+	}
+	missing := make(map[string]bool)
+
+	for _, order := range ords {
+		at := order.DateCreated
+
+		discounts := order.SellerDiscountByLine()
+
+		var om Margin
+		for i, item := range order.Items {
+			m := Margin{
+				Units:          item.Quantity,
+				Revenue:        item.UnitPrice * float64(item.Quantity),
+				SaleFee:        item.Fee(),
+				SellerDiscount: discounts[i],
+			}
+
+			unitCost, ok := t.UnitCost(item.SKU, at)
+			if !ok {
+				m.MissingCost = true
+				missing[item.SKU] = true
+			}
+			m.COGS = unitCost * float64(item.Quantity)
+			m.GrossMargin = m.Revenue - m.COGS
+			m.Contribution = m.Revenue - m.SaleFee - m.SellerDiscount - m.COGS
+
+			report.Items = append(report.Items, ItemMargin{
+				OrderID: order.OrderID,
+				ItemID:  item.ItemID,
+				SKU:     item.SKU,
+				Margin:  m,
+			})
+
+			sm := report.BySKU[item.SKU]
+			sm.add(m)
+			report.BySKU[item.SKU] = sm
+
+			om.add(m)
+		}
+
+		report.ByOrder[order.OrderID] = om
+		key := period(order)
+		pm := report.ByPeriod[key]
+		pm.add(om)
+		report.ByPeriod[key] = pm
+		report.Total.add(om)
+	}
+
+	for sku := range missing {
+		report.MissingSKUs = append(report.MissingSKUs, sku)
+	}
+	sort.Strings(report.MissingSKUs)
+
+	return report
+}
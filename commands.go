@@ -0,0 +1,577 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"maps"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"dimi/kkalcs/cogs"
+	"dimi/kkalcs/export"
+	"dimi/kkalcs/fanout"
+	"dimi/kkalcs/fees"
+	"dimi/kkalcs/mlapi"
+	"dimi/kkalcs/mlapi/ads"
+	"dimi/kkalcs/mlapi/auth"
+	"dimi/kkalcs/mlapi/billing"
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/shipments"
+	"dimi/kkalcs/period"
+	"dimi/kkalcs/profit"
+	"dimi/kkalcs/store"
+	"dimi/kkalcs/taxes"
+	"dimi/kkalcs/timezone"
+)
+
+// runCommand executa um subcomando da linha de comando.
+func runCommand(name string, args []string) error {
+	switch name {
+	case "export":
+		return exportCmd(args)
+	case "reconcile":
+		return reconcileCmd(args)
+	case "fees":
+		return feesCmd(args)
+	case "compare":
+		return compareCmd(args)
+	case "cancellations":
+		return cancellationsCmd(args)
+	case "sync":
+		return syncCmd(args)
+	default:
+		return fmt.Errorf("comando desconhecido: %s", name)
+	}
+}
+
+// periodFlags registra as flags de período, as mesmas aceitas pela API, e
+// retorna a função que monta o período depois do fs.Parse.
+func periodFlags(fs *flag.FlagSet) func() (period.Range, error) {
+	names := []struct{ name, usage string }{
+		{"from", "data inicial (AAAA-MM-DD)"},
+		{"to", "data final, inclusive (AAAA-MM-DD)"},
+		{"period", "período nomeado: month, billing, week ou quarter"},
+		{"year", "ano do período nomeado"},
+		{"month", "mês do período month ou billing"},
+		{"week", "semana ISO do período week"},
+		{"quarter", "trimestre do período quarter"},
+		{"cutoff", "dia de corte do período billing"},
+	}
+	values := make(map[string]*string, len(names))
+	for _, n := range names {
+		values[n.name] = fs.String(n.name, "", n.usage)
+	}
+
+	return func() (period.Range, error) {
+		query := url.Values{}
+		for name, v := range values {
+			if *v != "" {
+				query.Set(name, *v)
+			}
+		}
+		return period.Parse(query)
+	}
+}
+
+// exportCmd exporta um relatório a partir do banco local, sem chamar a API.
+// Ex.: kkalcs export -kind items -format csv -locale br -from 2025-02-21 -to 2025-03-21
+// ou kkalcs export -kind profit -period billing -year 2025 -month 3
+func exportCmd(args []string) error {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	kindStr := fs.String("kind", "orders", "relatório: orders, items, shipments, summary ou profit")
+	format := fs.String("format", "csv", "formato: csv ou xlsx")
+	localeStr := fs.String("locale", "", "br para separador ';' e vírgula decimal")
+	parsePeriod := periodFlags(fs)
+	out := fs.String("out", "", "arquivo de saída (padrão: <kind>.<format>)")
+	dbPath := fs.String("db", "kkalcs.db", "banco local")
+	fs.Parse(args)
+
+	kind, err := export.ParseKind(*kindStr)
+	if err != nil {
+		return err
+	}
+	locale, err := export.ParseLocale(*localeStr)
+	if err != nil {
+		return err
+	}
+	if *format != "csv" && *format != "xlsx" {
+		return fmt.Errorf("formato inválido: %s", *format)
+	}
+
+	p, err := parsePeriod()
+	if err != nil {
+		return err
+	}
+
+	db, err := store.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	data, err := loadExportData(db, kind, p.From, p.To)
+	if err != nil {
+		return err
+	}
+
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("%s.%s", kind, *format)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	table := kind.Table(*data)
+	if *format == "xlsx" {
+		err = export.WriteXLSX(f, table)
+	} else {
+		err = export.WriteCSV(f, table, locale)
+	}
+	if err != nil {
+		return fmt.Errorf("erro ao exportar: %s", err)
+	}
+
+	fmt.Println("Relatório salvo em", path)
+	return nil
+}
+
+// storedOrders lê do banco os pedidos do período que passam no filtro.
+func storedOrders(db *store.DB, dateFrom, dateTo time.Time, filter orders.Filter) ([]orders.Order, error) {
+	ords, err := db.OrdersBetween(dateFrom, dateTo)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler pedidos: %s", err)
+	}
+	return slices.DeleteFunc(ords, func(o orders.Order) bool { return !filter.Match(o) }), nil
+}
+
+func loadExportData(db *store.DB, kind export.Kind, dateFrom, dateTo time.Time) (*export.Data, error) {
+	matched, err := storedOrders(db, dateFrom, dateTo, orders.DefaultFilter())
+	if err != nil {
+		return nil, err
+	}
+	data := &export.Data{Orders: matched}
+
+	if !kind.NeedsShipments() {
+		return data, nil
+	}
+
+	var ids []string
+	for _, pack := range orders.GroupPacks(matched) {
+		if pack.ShippingID != 0 {
+			ids = append(ids, strconv.Itoa(pack.ShippingID))
+		}
+	}
+	costs, err := db.ShipmentCosts(ids)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler custos de envio: %s", err)
+	}
+	for _, c := range costs {
+		data.ShipmentCosts = append(data.ShipmentCosts, c)
+	}
+
+	if kind == export.KindProfit {
+		rules, err := taxes.LoadIfExists(taxes.RulesFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao carregar regras de impostos: %s", err)
+		}
+		in, err := profit.LoadInputs(db, matched, dateFrom, dateTo, rules)
+		if err != nil {
+			return nil, err
+		}
+		data.Ledger = profit.Build(in)
+	}
+
+	return data, nil
+}
+
+// reconcileCmd compara as cobranças de um período de faturamento com as
+// tarifas e fretes calculados e lista as diferenças por pedido. Pedidos e
+// custos de envio que faltam no banco são buscados e salvos.
+// Ex.: kkalcs reconcile -key 2025-03-01 -tolerance 0.01
+func reconcileCmd(args []string) error {
+	fs := flag.NewFlagSet("reconcile", flag.ExitOnError)
+	key := fs.String("key", "", "período de faturamento (padrão: o mais recente)")
+	tolerance := fs.Float64("tolerance", 0.01, "diferença aceita, em reais")
+	dbPath := fs.String("db", "kkalcs.db", "banco local")
+	fs.Parse(args)
+
+	c, err := auth.NewClient()
+	if err != nil {
+		return err
+	}
+
+	billed, err := billing.FindPeriod(c, *key)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar período de faturamento: %s", err)
+	}
+	*key = billed.Key
+	window, err := billed.Range()
+	if err != nil {
+		return err
+	}
+
+	db, err := store.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	summary, err := billing.FetchSummary(c, *key)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar resumo do faturamento: %s", err)
+	}
+	details, err := billing.FetchDetails(c, *key)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar cobranças: %s", err)
+	}
+
+	ords, err := billedOrders(c, db, billing.OrderIDs(details))
+	if err != nil {
+		return err
+	}
+	costs, err := billedShipmentCosts(c, db, ords)
+	if err != nil {
+		return err
+	}
+
+	metrics, err := ads.FetchAll(c, window.From, window.To)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar anúncios: %s", err)
+	}
+	var adsSpend float64
+	for _, m := range metrics {
+		adsSpend += m.Cost
+	}
+
+	report := billing.Reconcile(details, billing.Computed{
+		Orders:        ords,
+		ShipmentCosts: costs,
+		AdsSpend:      adsSpend,
+	}, *tolerance)
+
+	fmt.Println("Período:", *key, window.String(), "Total faturado:", summary.Total)
+	for kind, t := range report.ByKind {
+		if !t.Compared {
+			fmt.Println("  Componente:", kind, "Cobrado:", t.Charged, "(não conciliado)")
+			continue
+		}
+		fmt.Println("  Componente:", kind, "Cobrado:", t.Charged, "Calculado:", t.Computed)
+	}
+	for _, d := range report.Discrepancies {
+		if d.OrderID == 0 {
+			fmt.Println("  Período:", *key, "Componente:", d.Kind, "Cobrado:", d.Charged, "Calculado:", d.Computed, "Diferença:", d.Difference)
+			continue
+		}
+		fmt.Println("  Pedido:", d.OrderID, "Envio:", d.ShippingID, "Componente:", d.Kind, "Cobrado:", d.Charged, "Calculado:", d.Computed, "Diferença:", d.Difference)
+	}
+	if len(report.Unmatched) > 0 {
+		fmt.Println("Cobranças sem pedido ou envio conhecido:", len(report.Unmatched))
+	}
+	return nil
+}
+
+// billedOrders lê os pedidos do banco e busca no Mercado Livre os que faltam.
+func billedOrders(c *mlapi.Client, db *store.DB, ids []int64) ([]orders.Order, error) {
+	var ords []orders.Order
+	var missing []int64
+	for _, id := range ids {
+		order, err := db.Order(id)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao ler pedido: %s", err)
+		}
+		if order == nil {
+			missing = append(missing, id)
+			continue
+		}
+		ords = append(ords, *order)
+	}
+
+	fetched, errs := fanout.Run(missing, fanout.Options{Workers: 8, Interval: 50 * time.Millisecond}, func(id int64) (*orders.Order, error) {
+		return orders.Get(c, strconv.FormatInt(id, 10))
+	})
+	for id, err := range errs {
+		fmt.Println("Erro ao buscar pedido:", err, "ORDER_ID: ", id)
+	}
+
+	var news []orders.Order
+	for _, order := range fetched {
+		news = append(news, *order)
+	}
+	if err := db.UpsertOrders(news); err != nil {
+		return nil, fmt.Errorf("erro ao salvar pedidos: %s", err)
+	}
+
+	return append(ords, news...), nil
+}
+
+// billedShipmentCosts lê os custos de envio dos packs do banco e busca os que faltam.
+func billedShipmentCosts(c *mlapi.Client, db *store.DB, ords []orders.Order) (map[string]shipments.ShipmentCost, error) {
+	var ids []string
+	soldAt := make(map[string]time.Time)
+	for _, pack := range orders.GroupPacks(ords) {
+		if pack.ShippingID != 0 {
+			id := strconv.Itoa(pack.ShippingID)
+			ids = append(ids, id)
+			soldAt[id] = pack.SoldAt()
+		}
+	}
+
+	costs, err := db.ShipmentCosts(ids)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler custos de envio: %s", err)
+	}
+	missing := shipments.Missing(ids, costs, soldAt)
+
+	fetched, errs := fanout.Run(missing, fanout.Options{Workers: 8, Interval: 50 * time.Millisecond}, func(id string) (*shipments.ShipmentCost, error) {
+		return shipments.FetchCosts(c, id)
+	})
+	for id, err := range errs {
+		fmt.Println("Erro:", err, "SHIPMENT_ID: ", id)
+	}
+
+	var news []shipments.ShipmentCost
+	for id, cost := range fetched {
+		costs[id] = *cost
+		news = append(news, *cost)
+	}
+	if err := db.UpsertShipmentCosts(news); err != nil {
+		return nil, fmt.Errorf("erro ao salvar custos de envio: %s", err)
+	}
+
+	return costs, nil
+}
+
+// feesCmd confere as tarifas de venda dos pedidos do banco no período contra a
+// tarifa informada pelo Mercado Livre para a categoria, o tipo de anúncio e o
+// preço de cada item.
+// Ex.: kkalcs fees -period month -year 2025 -month 3 -tolerance 0.05
+func feesCmd(args []string) error {
+	fs := flag.NewFlagSet("fees", flag.ExitOnError)
+	parsePeriod := periodFlags(fs)
+	tolerance := fs.Float64("tolerance", 0.01, "diferença aceita por unidade, em reais")
+	dbPath := fs.String("db", "kkalcs.db", "banco local")
+	fs.Parse(args)
+
+	p, err := parsePeriod()
+	if err != nil {
+		return err
+	}
+
+	db, err := store.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ords, err := storedOrders(db, p.From, p.To, orders.DefaultFilter())
+	if err != nil {
+		return err
+	}
+
+	c, err := auth.NewClient()
+	if err != nil {
+		return err
+	}
+
+	anomalies, errs := fees.Check(c, ords, *tolerance)
+	for q, err := range errs {
+		fmt.Println("Erro ao buscar tarifa:", err, "CONSULTA: ", q)
+	}
+
+	var total float64
+	for _, a := range anomalies {
+		total += a.Overcharged()
+		fmt.Println("  Pedido:", a.OrderID, "Item:", a.ItemID, "Tipo:", orders.ListingTypeName(a.ListingTypeID), "Preço:", a.UnitPrice,
+			"Cobrado:", a.Charged, "Esperado:", a.Expected, "Diferença:", a.Difference)
+	}
+	fmt.Println("Itens com tarifa divergente:", len(anomalies), "Cobrado a mais:", total)
+	return nil
+}
+
+// compareCmd compara o resumo de vendas do período com o período anterior e/ou
+// o mesmo período do ano anterior, a partir do banco local.
+// Ex.: kkalcs compare -period month -year 2025 -month 3 -against previous,last_year
+func compareCmd(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ExitOnError)
+	parsePeriod := periodFlags(fs)
+	againstStr := fs.String("against", "previous,last_year", "períodos de comparação: previous e/ou last_year")
+	dbPath := fs.String("db", "kkalcs.db", "banco local")
+	fs.Parse(args)
+
+	p, err := parsePeriod()
+	if err != nil {
+		return err
+	}
+	against, err := period.Against(p, *againstStr)
+	if err != nil {
+		return err
+	}
+
+	db, err := store.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	current, err := storedOrders(db, p.From, p.To, orders.DefaultFilter())
+	if err != nil {
+		return err
+	}
+	costs, err := db.UnitCosts()
+	if err != nil {
+		return fmt.Errorf("erro ao ler custos: %s", err)
+	}
+	currentMargins := cogs.Margins(current, costs, orders.Monthly)
+
+	report := orders.ComparisonReport{Period: p.String(), Summary: orders.Total(current)}
+	var margins []cogs.Comparison
+	for _, a := range against {
+		ords, err := storedOrders(db, a.From, a.To, orders.DefaultFilter())
+		if err != nil {
+			return err
+		}
+		report.Add(a.String(), orders.Total(ords))
+		margins = append(margins, cogs.Compare(a.String(), currentMargins, cogs.Margins(ords, costs, orders.Monthly)))
+	}
+
+	fmt.Println("Período:", report.Period)
+	for i, c := range report.Against {
+		fmt.Println("Comparado com:", c.Period)
+		printDelta("  ", c.Total)
+		printMarginDelta("  ", margins[i].Total)
+		for _, category := range slices.Sorted(maps.Keys(c.ByCategory)) {
+			fmt.Println("  Categoria:", category)
+			printDelta("    ", c.ByCategory[category])
+		}
+		for _, sku := range slices.Sorted(maps.Keys(c.BySKU)) {
+			fmt.Println("  SKU:", sku)
+			printDelta("    ", c.BySKU[sku])
+			printMarginDelta("    ", margins[i].BySKU[sku])
+		}
+	}
+	return nil
+}
+
+func printDelta(indent string, d orders.BreakdownDelta) {
+	metrics := []struct {
+		name  string
+		delta orders.Delta
+	}{
+		{"Bruto", d.Gross},
+		{"Tarifas", d.SaleFee},
+		{"Líquido", d.Net},
+		{"Margem após tarifas (sem CMV)", d.NetMargin},
+		{"Unidades", d.Units},
+		{"Ticket médio", d.AverageTicket},
+	}
+	for _, m := range metrics {
+		printMetric(indent, m.name, m.delta)
+	}
+}
+
+func printMarginDelta(indent string, d cogs.MarginDelta) {
+	metrics := []struct {
+		name  string
+		delta orders.Delta
+	}{
+		{"CMV", d.COGS},
+		{"Margem bruta", d.GrossMargin},
+		{"Margem de contribuição", d.Contribution},
+		{"Margem de contribuição (%)", d.ContributionRate},
+	}
+	for _, m := range metrics {
+		printMetric(indent, m.name, m.delta)
+	}
+	if d.MissingCost {
+		fmt.Println(indent + "Atenção: há itens sem custo, o CMV está subestimado")
+	}
+}
+
+func printMetric(indent, name string, d orders.Delta) {
+	percent := "n/a"
+	if d.Percent != nil {
+		percent = fmt.Sprintf("%+.1f%%", *d.Percent*100)
+	}
+	fmt.Println(indent+name+":", d.Current, "Antes:", d.Previous, "Variação:", d.Change, percent)
+}
+
+// cancellationsCmd lê do banco os pedidos pagos e cancelados do período e mostra
+// o impacto dos cancelamentos e reembolsos. Os cancelados só chegam ao banco
+// pelo sync, que não filtra por status.
+// Ex.: kkalcs cancellations -period month -year 2025 -month 3
+func cancellationsCmd(args []string) error {
+	fs := flag.NewFlagSet("cancellations", flag.ExitOnError)
+	parsePeriod := periodFlags(fs)
+	dbPath := fs.String("db", "kkalcs.db", "banco local")
+	fs.Parse(args)
+
+	p, err := parsePeriod()
+	if err != nil {
+		return err
+	}
+
+	db, err := store.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	ords, err := storedOrders(db, p.From, p.To, orders.CancellationFilter())
+	if err != nil {
+		return err
+	}
+	report := orders.Cancellations(ords)
+
+	fmt.Println("Período:", p.String())
+	fmt.Println("Pedidos cancelados:", report.CancelledOrders, "Receita perdida:", report.LostRevenue)
+	fmt.Println("Tarifas cobradas:", report.FeesCharged, "Devolvidas:", report.FeesRefunded, "Retidas:", report.FeesKept)
+	fmt.Println("Reembolsos parciais:", report.PartiallyRefundedOrders, "Valor:", report.PartiallyRefundedAmount)
+	fmt.Println("Reembolsos totais sem cancelamento:", report.FullyRefundedOrders, "Valor:", report.FullyRefundedAmount)
+	for _, requester := range slices.Sorted(maps.Keys(report.ByRequester)) {
+		fmt.Println("  Solicitado por:", requester, "Pedidos:", report.ByRequester[requester])
+	}
+	for _, reason := range slices.Sorted(maps.Keys(report.ByReason)) {
+		fmt.Println("  Motivo:", reason, "Pedidos:", report.ByReason[reason])
+	}
+	return nil
+}
+
+// syncCmd atualiza o banco local apenas com os pedidos alterados desde a última
+// sincronização. Na primeira execução busca os pedidos alterados desde -from.
+func syncCmd(args []string) error {
+	fs := flag.NewFlagSet("sync", flag.ExitOnError)
+	dbPath := fs.String("db", "kkalcs.db", "banco local")
+	from := fs.String("from", "2025-01-01", "data inicial da primeira sincronização (AAAA-MM-DD)")
+	fs.Parse(args)
+
+	initialFrom, err := timezone.ParseDate(*from)
+	if err != nil {
+		return fmt.Errorf("data inicial inválida: %s", err)
+	}
+
+	db, err := store.Open(*dbPath)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	c, err := auth.NewClient()
+	if err != nil {
+		return err
+	}
+
+	result, err := orders.Sync(c, db, initialFrom)
+	if err != nil {
+		return fmt.Errorf("erro ao sincronizar pedidos: %s", err)
+	}
+
+	fmt.Println("Sincronizado de", timezone.In(result.From).Format(time.DateTime), "até", timezone.In(result.To).Format(time.DateTime))
+	fmt.Println("Pedidos alterados:", result.Fetched)
+	return nil
+}
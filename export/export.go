@@ -0,0 +1,165 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Table é uma planilha: um cabeçalho e linhas com string, int ou float64.
+type Table struct {
+	Name   string
+	Header []string
+	Rows   [][]any
+}
+
+// Rate é uma taxa (ex.: 0,1432), escrita com mais casas decimais que os valores.
+type Rate float64
+
+// Locale define como números e colunas são escritos no CSV.
+type Locale struct {
+	Separator    rune
+	DecimalComma bool
+}
+
+var (
+	DefaultLocale = Locale{Separator: ',', DecimalComma: false}
+	// BrazilianLocale usa ";" entre colunas e vírgula decimal, que é o que o
+	// Excel em português espera ao abrir um CSV.
+	BrazilianLocale = Locale{Separator: ';', DecimalComma: true}
+)
+
+// ParseLocale aceita "br"/"pt-BR" ou vazio/"en" para o padrão.
+func ParseLocale(s string) (Locale, error) {
+	switch strings.ToLower(s) {
+	case "", "en", "default":
+		return DefaultLocale, nil
+	case "br", "pt-br", "pt_br":
+		return BrazilianLocale, nil
+	default:
+		return Locale{}, fmt.Errorf("locale desconhecido: %q", s)
+	}
+}
+
+func (l Locale) format(v any) string {
+	switch val := v.(type) {
+	case float64:
+		return l.formatFloat(val, 2)
+	case Rate:
+		return l.formatFloat(float64(val), 4)
+	case int:
+		return strconv.Itoa(val)
+	case int64:
+		return strconv.FormatInt(val, 10)
+	case bool:
+		return strconv.FormatBool(val)
+	case string:
+		return val
+	default:
+		return fmt.Sprint(val)
+	}
+}
+
+func (l Locale) formatFloat(v float64, prec int) string {
+	s := strconv.FormatFloat(v, 'f', prec, 64)
+	if l.DecimalComma {
+		s = strings.Replace(s, ".", ",", 1)
+	}
+	return s
+}
+
+func WriteCSV(w io.Writer, t Table, l Locale) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = l.Separator
+
+	err := cw.Write(t.Header)
+	if err != nil {
+		return err
+	}
+
+	record := make([]string, len(t.Header))
+	for _, row := range t.Rows {
+		for i, v := range row {
+			record[i] = l.format(v)
+		}
+		err = cw.Write(record[:len(row)])
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteXLSX escreve cada tabela em uma aba. Os números ficam como números, e o
+// Excel os mostra no formato do computador de quem abrir.
+func WriteXLSX(w io.Writer, tables ...Table) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	for i, t := range tables {
+		name := t.Name
+		if name == "" {
+			name = fmt.Sprintf("Sheet%d", i+1)
+		}
+		if i == 0 {
+			err := f.SetSheetName("Sheet1", name)
+			if err != nil {
+				return err
+			}
+		} else if _, err := f.NewSheet(name); err != nil {
+			return err
+		}
+
+		sw, err := f.NewStreamWriter(name)
+		if err != nil {
+			return err
+		}
+
+		header := make([]any, len(t.Header))
+		for j, h := range t.Header {
+			header[j] = h
+		}
+		err = sw.SetRow("A1", header)
+		if err != nil {
+			return err
+		}
+
+		for r, row := range t.Rows {
+			cell, err := excelize.CoordinatesToCellName(1, r+2)
+			if err != nil {
+				return err
+			}
+			err = sw.SetRow(cell, xlsxRow(row))
+			if err != nil {
+				return err
+			}
+		}
+
+		err = sw.Flush()
+		if err != nil {
+			return err
+		}
+	}
+
+	return f.Write(w)
+}
+
+// xlsxRow converte os tipos próprios do pacote para tipos que o excelize grava
+// como número.
+func xlsxRow(row []any) []any {
+	out := make([]any, len(row))
+	for i, v := range row {
+		if r, ok := v.(Rate); ok {
+			out[i] = float64(r)
+			continue
+		}
+		out[i] = v
+	}
+	return out
+}
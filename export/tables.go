@@ -0,0 +1,187 @@
+package export
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/shipments"
+	"dimi/kkalcs/profit"
+	"dimi/kkalcs/timezone"
+)
+
+// Kind é o relatório a exportar.
+type Kind string
+
+const (
+	KindOrders    Kind = "orders"
+	KindItems     Kind = "items"
+	KindShipments Kind = "shipments"
+	KindSummary   Kind = "summary"
+	KindProfit    Kind = "profit"
+)
+
+func ParseKind(s string) (Kind, error) {
+	switch k := Kind(s); k {
+	case KindOrders, KindItems, KindShipments, KindSummary, KindProfit:
+		return k, nil
+	default:
+		return "", fmt.Errorf("tipo de relatório desconhecido: %q", s)
+	}
+}
+
+func OrdersTable(ords []orders.Order) Table {
+	t := Table{
+		Name:   "orders",
+		Header: []string{"order_id", "pack_id", "date_created", "status", "shipping_id", "items", "total_amount", "paid_amount", "gross", "sale_fee", "seller_discount"},
+	}
+	for _, o := range ords {
+		var fee, discount float64
+		for _, item := range o.Items {
+			fee += item.Fee()
+		}
+		if o.Discounts != nil {
+			discount = o.Discounts.SellerFunded()
+		}
+		t.Rows = append(t.Rows, []any{
+			o.OrderID, o.PackID, formatDate(o.DateCreated), o.Status, o.ShippingID, len(o.Items),
+			o.TotalAmount, o.PaidAmount, o.Gross(), fee, discount,
+		})
+	}
+	return t
+}
+
+func ItemsTable(ords []orders.Order) Table {
+	t := Table{
+		Name:   "items",
+		Header: []string{"order_id", "date_created", "item_id", "title", "seller_sku", "category_id", "listing_type", "quantity", "unit_price", "gross", "sale_fee"},
+	}
+	for _, o := range ords {
+		for _, item := range o.Items {
+			t.Rows = append(t.Rows, []any{
+				o.OrderID, formatDate(o.DateCreated), item.ItemID, item.Title, item.SKU, item.CategoryID,
+				orders.ListingTypeName(item.ListingTypeID), item.Quantity, item.UnitPrice, item.Gross(), item.Fee(),
+			})
+		}
+	}
+	return t
+}
+
+func ShipmentsTable(costs []shipments.ShipmentCost) Table {
+	t := Table{
+		Name:   "shipments",
+		Header: []string{"shipment_id", "cost", "charge_flex", "discount", "final_cost"},
+	}
+	sorted := append([]shipments.ShipmentCost(nil), costs...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].ShipmentID < sorted[j].ShipmentID })
+	for _, s := range sorted {
+		t.Rows = append(t.Rows, []any{s.ShipmentID, s.Cost, s.ChargeFlex, s.Discount, s.FinalCost})
+	}
+	return t
+}
+
+var breakdownHeader = []string{"group", "key", "orders", "units", "gross", "sale_fee", "seller_discount", "net", "fee_rate", "average_ticket"}
+
+func breakdownRow(group, key string, b orders.Breakdown) []any {
+	return []any{group, key, b.Orders, b.Units, b.Gross, b.SaleFee, b.SellerDiscount, b.Net, Rate(b.FeeRate), b.AverageTicket}
+}
+
+// SummaryTable escreve o total e cada quebra do resumo como linhas, com a
+// coluna group indicando a quebra.
+func SummaryTable(s orders.Summary) Table {
+	t := Table{Name: "summary", Header: breakdownHeader}
+	t.Rows = append(t.Rows, breakdownRow("total", "", s.Breakdown))
+
+	groups := []struct {
+		name string
+		m    map[string]orders.Breakdown
+	}{
+		{"listing_type", s.ByListingType},
+		{"category", s.ByCategory},
+		{"sku", s.BySKU},
+		{"day", s.ByDay},
+	}
+	for _, g := range groups {
+		for _, key := range sortedKeys(g.m) {
+			t.Rows = append(t.Rows, breakdownRow(g.name, key, g.m[key]))
+		}
+	}
+	return t
+}
+
+var profitComponents = []profit.Component{
+	profit.GrossSale, profit.SaleFee, profit.Shipping, profit.SellerDiscount,
+	profit.Ads, profit.Taxes, profit.COGS, profit.Refunds,
+}
+
+// ProfitTable escreve uma linha por item do ledger, com uma coluna por componente.
+func ProfitTable(l profit.Ledger) Table {
+	t := Table{
+		Name:   "profit",
+		Header: []string{"order_id", "pack_id", "period", "item_id", "seller_sku", "category_id", "listing_type", "quantity"},
+	}
+	for _, c := range profitComponents {
+		t.Header = append(t.Header, string(c))
+	}
+	t.Header = append(t.Header, "net", "missing_cost")
+
+	for _, line := range l.Lines() {
+		row := []any{
+			line.OrderID, line.PackID, line.Period, line.ItemID, line.SKU, line.CategoryID,
+			orders.ListingTypeName(line.ListingTypeID), line.Quantity,
+		}
+		for _, c := range profitComponents {
+			row = append(row, line.Amount(c))
+		}
+		row = append(row, line.Net(), line.MissingCost)
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Data são os dados disponíveis para montar os relatórios.
+type Data struct {
+	Orders        []orders.Order
+	ShipmentCosts []shipments.ShipmentCost
+	Ledger        profit.Ledger
+}
+
+// Table monta a tabela do relatório a partir dos dados.
+func (k Kind) Table(d Data) Table {
+	switch k {
+	case KindItems:
+		return ItemsTable(d.Orders)
+	case KindShipments:
+		return ShipmentsTable(d.ShipmentCosts)
+	case KindSummary:
+		return SummaryTable(orders.Total(d.Orders))
+	case KindProfit:
+		return ProfitTable(d.Ledger)
+	default:
+		return OrdersTable(d.Orders)
+	}
+}
+
+// NeedsShipments informa se o relatório usa os custos de envio.
+func (k Kind) NeedsShipments() bool {
+	return k == KindShipments || k == KindProfit
+}
+
+// formatDate escreve a data no fuso configurado, no formato aceito pelas
+// planilhas como data e hora.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return timezone.In(t).Format("2006-01-02 15:04:05")
+}
@@ -0,0 +1,95 @@
+package fanout
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+type Options struct {
+	// Workers é a quantidade de chamadas simultâneas. Zero ou negativo usa 1.
+	Workers int
+	// Interval é o tempo mínimo entre o início de duas chamadas, usado para não
+	// estourar o rate limit da API. Zero desativa o limite.
+	Interval time.Duration
+	// Progress, se definido, é chamado após cada chave processada.
+	Progress func(done, total int)
+}
+
+// Errors agrega os erros de cada chave que falhou.
+type Errors[K comparable] map[K]error
+
+// Err junta todos os erros em um só, ou retorna nil se nenhuma chave falhou.
+func (e Errors[K]) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(e))
+	for k, err := range e {
+		errs = append(errs, fmt.Errorf("%v: %w", k, err))
+	}
+	return errors.Join(errs...)
+}
+
+// Run chama fn para cada chave usando opts.Workers goroutines e devolve os
+// resultados e os erros indexados pela chave. Chaves repetidas são processadas uma vez.
+func Run[K comparable, T any](keys []K, opts Options, fn func(K) (T, error)) (map[K]T, Errors[K]) {
+	workers := max(opts.Workers, 1)
+
+	var limiter <-chan time.Time
+	if opts.Interval > 0 {
+		ticker := time.NewTicker(opts.Interval)
+		defer ticker.Stop()
+		limiter = ticker.C
+	}
+
+	jobs := make(chan K)
+	results := make(map[K]T)
+	errs := make(Errors[K])
+	done := 0
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	seen := make(map[K]bool, len(keys))
+	unique := make([]K, 0, len(keys))
+	for _, k := range keys {
+		if !seen[k] {
+			seen[k] = true
+			unique = append(unique, k)
+		}
+	}
+
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for k := range jobs {
+				v, err := fn(k)
+
+				mu.Lock()
+				if err != nil {
+					errs[k] = err
+				} else {
+					results[k] = v
+				}
+				done++
+				if opts.Progress != nil {
+					opts.Progress(done, len(unique))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, k := range unique {
+		if limiter != nil {
+			<-limiter
+		}
+		jobs <- k
+	}
+	close(jobs)
+	wg.Wait()
+
+	return results, errs
+}
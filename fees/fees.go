@@ -0,0 +1,136 @@
+// Package fees confere as tarifas de venda cobradas nos pedidos contra a
+// tarifa que o Mercado Livre informa para a categoria, o tipo de anúncio e o
+// preço de cada item.
+package fees
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"dimi/kkalcs/fanout"
+	"dimi/kkalcs/mlapi"
+	"dimi/kkalcs/mlapi/categories"
+	"dimi/kkalcs/mlapi/orders"
+)
+
+// Quote identifica uma consulta de tarifa. Itens com a mesma categoria, tipo
+// de anúncio e preço compartilham a consulta.
+type Quote struct {
+	CategoryID    string
+	ListingTypeID string
+	UnitPrice     float64
+}
+
+func (q Quote) String() string {
+	return fmt.Sprintf("%s/%s/%.2f", q.CategoryID, q.ListingTypeID, q.UnitPrice)
+}
+
+// Anomaly é um item cuja tarifa cobrada difere da esperada além da tolerância.
+// Charged, Expected e Difference são por unidade, como OrderItem.SaleFee e a
+// tarifa de listing_prices; Overcharged é o total da linha, como OrderItem.Fee.
+type Anomaly struct {
+	OrderID       int64   `json:"order_id"`
+	ItemID        string  `json:"item_id"`
+	SKU           string  `json:"seller_sku"`
+	CategoryID    string  `json:"category_id"`
+	ListingTypeID string  `json:"listing_type_id"`
+	UnitPrice     float64 `json:"unit_price"`
+	Quantity      int     `json:"quantity"`
+	Charged       float64 `json:"charged"`
+	Expected      float64 `json:"expected"`
+	Difference    float64 `json:"difference"` // Charged - Expected
+}
+
+// Overcharged é quanto foi cobrado a mais no item, somando todas as unidades.
+// Negativo se foi cobrado a menos.
+func (a Anomaly) Overcharged() float64 {
+	return a.Difference * float64(a.Quantity)
+}
+
+// Expected busca a tarifa esperada de cada consulta, em paralelo.
+func Expected(c *mlapi.Client, quotes []Quote) (map[Quote]float64, fanout.Errors[Quote]) {
+	opts := fanout.Options{Workers: 8, Interval: 50 * time.Millisecond}
+	return fanout.Run(quotes, opts, func(q Quote) (float64, error) {
+		prices, err := categories.GetListingPrices(c, q.CategoryID, q.UnitPrice, q.ListingTypeID)
+		if err != nil {
+			return 0, err
+		}
+		for _, p := range prices {
+			if p.ListingTypeID == q.ListingTypeID {
+				return p.SaleFeeAmount, nil
+			}
+		}
+		return 0, fmt.Errorf("tarifa não encontrada para o tipo de anúncio %s", q.ListingTypeID)
+	})
+}
+
+// Quotes retorna as consultas necessárias para conferir os pedidos. Itens sem
+// categoria ou tipo de anúncio são ignorados.
+func Quotes(ords []orders.Order) []Quote {
+	var quotes []Quote
+	for _, order := range ords {
+		for _, item := range order.Items {
+			if q, ok := quoteFor(item); ok {
+				quotes = append(quotes, q)
+			}
+		}
+	}
+	return quotes
+}
+
+func quoteFor(item orders.OrderItem) (Quote, bool) {
+	if item.CategoryID == "" || item.ListingTypeID == "" {
+		return Quote{}, false
+	}
+	return Quote{CategoryID: item.CategoryID, ListingTypeID: item.ListingTypeID, UnitPrice: item.UnitPrice}, true
+}
+
+// Compare confere cada item contra a tarifa esperada e retorna os que diferem
+// mais que tolerance, do maior valor cobrado a mais para o menor. Itens cuja
+// tarifa esperada não foi encontrada são ignorados.
+func Compare(ords []orders.Order, expected map[Quote]float64, tolerance float64) []Anomaly {
+	var anomalies []Anomaly
+	for _, order := range ords {
+		for _, item := range order.Items {
+			q, ok := quoteFor(item)
+			if !ok {
+				continue
+			}
+			fee, ok := expected[q]
+			if !ok {
+				continue
+			}
+
+			diff := item.SaleFee - fee
+			if math.Abs(diff) <= tolerance {
+				continue
+			}
+			anomalies = append(anomalies, Anomaly{
+				OrderID:       order.OrderID,
+				ItemID:        item.ItemID,
+				SKU:           item.SKU,
+				CategoryID:    item.CategoryID,
+				ListingTypeID: item.ListingTypeID,
+				UnitPrice:     item.UnitPrice,
+				Quantity:      item.Quantity,
+				Charged:       item.SaleFee,
+				Expected:      fee,
+				Difference:    diff,
+			})
+		}
+	}
+
+	sort.SliceStable(anomalies, func(i, j int) bool {
+		return anomalies[i].Overcharged() > anomalies[j].Overcharged()
+	})
+	return anomalies
+}
+
+// Check busca as tarifas esperadas e confere os pedidos. As consultas que
+// falharam são retornadas em errs e seus itens ficam de fora.
+func Check(c *mlapi.Client, ords []orders.Order, tolerance float64) ([]Anomaly, fanout.Errors[Quote]) {
+	expected, errs := Expected(c, Quotes(ords))
+	return Compare(ords, expected, tolerance), errs
+}
@@ -47,6 +47,6 @@ func SetupLogger() {
 		},
 	})
 
-	logger := slog.New(&requestIDHandler{Handler: h})
+	logger := slog.New(&requestIDHandler{Handler: &redactHandler{Handler: h}})
 	slog.SetDefault(logger)
 }
@@ -0,0 +1,183 @@
+package logger
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretKeys são campos e parâmetros cujo valor nunca deve aparecer em logs ou erros.
+var secretKeys = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"client_secret": true,
+	"sign":          true,
+	"password":      true,
+	"authorization": true,
+}
+
+// piiKeys são campos com dados pessoais do comprador. Objetos inteiros como
+// endereços também são mascarados.
+var piiKeys = map[string]bool{
+	"first_name":        true,
+	"last_name":         true,
+	"email":             true,
+	"phone":             true,
+	"buyer_username":    true,
+	"full_address":      true,
+	"address_line":      true,
+	"street_name":       true,
+	"street_number":     true,
+	"zip_code":          true,
+	"zipcode":           true,
+	"doc_number":        true,
+	"identification":    true,
+	"billing_info":      true,
+	"recipient_address": true,
+	"receiver_address":  true,
+	"receiver_name":     true,
+	"receiver_phone":    true,
+}
+
+var redactPatterns = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	// Parâmetros de query: ?access_token=...&sign=...
+	{regexp.MustCompile(`(?i)([?&](?:access_token|refresh_token|client_secret|sign|code)=)[^&\s"']+`), "${1}" + redacted},
+	// Campos JSON com valor string, inclusive em JSON truncado ou inválido.
+	{regexp.MustCompile(`(?i)("(?:access_token|refresh_token|client_secret|sign|password|first_name|last_name|email|buyer_username|full_address|phone|zipcode|zip_code|doc_number)"\s*:\s*)"(?:[^"\\]|\\.)*"`), "${1}\"" + redacted + "\""},
+	{regexp.MustCompile(`(?i)(Bearer\s+)[A-Za-z0-9\-._~+/]+=*`), "${1}" + redacted},
+	// Tokens do Mercado Livre soltos no texto.
+	{regexp.MustCompile(`APP_USR-[0-9A-Za-z\-]+`), redacted},
+	{regexp.MustCompile(`TG-[0-9A-Za-z\-]+`), redacted},
+}
+
+// Redact mascara tokens, assinaturas, segredos e dados pessoais do comprador em s.
+// Se s for um JSON válido, os campos sensíveis são mascarados pela chave.
+func Redact(s string) string {
+	trimmed := strings.TrimSpace(s)
+	if strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var v any
+		if err := json.Unmarshal([]byte(trimmed), &v); err == nil {
+			if out, err := json.Marshal(redactValue(v)); err == nil {
+				s = string(out)
+			}
+		}
+	}
+
+	for _, p := range redactPatterns {
+		s = p.re.ReplaceAllString(s, p.repl)
+	}
+	return s
+}
+
+func redactValue(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		for k, child := range val {
+			key := strings.ToLower(k)
+			if secretKeys[key] || piiKeys[key] {
+				val[k] = redacted
+				continue
+			}
+			val[k] = redactValue(child)
+		}
+		return val
+	case []any:
+		for i, child := range val {
+			val[i] = redactValue(child)
+		}
+		return val
+	default:
+		return v
+	}
+}
+
+// Mask esconde um dado pessoal mantendo apenas o primeiro caractere, para que
+// ainda seja possível diferenciar registros no terminal.
+func Mask(s string) string {
+	if s == "" {
+		return ""
+	}
+	r := []rune(s)
+	return string(r[0]) + "***"
+}
+
+type redactedError struct {
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return Redact(e.err.Error())
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// RedactError envolve err para que sua mensagem passe por Redact, mantendo o
+// erro original acessível via errors.Is e errors.As.
+func RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &redactedError{err: err}
+}
+
+// redactHandler aplica Redact à mensagem e aos atributos de cada log.
+type redactHandler struct {
+	slog.Handler
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	nr := slog.NewRecord(r.Time, r.Level, Redact(r.Message), r.PC)
+	r.Attrs(func(a slog.Attr) bool {
+		nr.AddAttrs(redactAttr(a))
+		return true
+	})
+	return h.Handler.Handle(ctx, nr)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	// O slice pertence a quem chamou, então é copiado antes de mascarar.
+	redacted := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		redacted[i] = redactAttr(a)
+	}
+	return &redactHandler{Handler: h.Handler.WithAttrs(redacted)}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+func redactAttr(a slog.Attr) slog.Attr {
+	if key := strings.ToLower(a.Key); secretKeys[key] || piiKeys[key] {
+		return slog.String(a.Key, redacted)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(v.String()))
+	case slog.KindGroup:
+		group := v.Group()
+		out := make([]any, len(group))
+		for i, ga := range group {
+			out[i] = redactAttr(ga)
+		}
+		return slog.Group(a.Key, out...)
+	case slog.KindAny:
+		if err, ok := v.Any().(error); ok {
+			return slog.String(a.Key, Redact(err.Error()))
+		}
+		return slog.String(a.Key, Redact(v.String()))
+	default:
+		return a
+	}
+}
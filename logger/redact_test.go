@@ -0,0 +1,153 @@
+package logger
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		name    string
+		in      string
+		hidden  []string
+		visible []string
+	}{
+		{
+			name:    "query parameters",
+			in:      "GET https://api.mercadolibre.com/oauth/token?code=TG-abc123&client_secret=s3cr3t&grant_type=x",
+			hidden:  []string{"TG-abc123", "s3cr3t"},
+			visible: []string{"grant_type=x"},
+		},
+		{
+			name:    "bearer token",
+			in:      "Authorization: Bearer APP_USR-1234-abcd",
+			hidden:  []string{"APP_USR-1234-abcd"},
+			visible: []string{"Authorization"},
+		},
+		{
+			name:    "nested JSON personal data",
+			in:      `{"id": 1, "buyer": {"email": "a@b.com", "phone": {"number": "99999"}, "nickname": "NICK"}, "receiver_address": {"street_name": "Rua X"}}`,
+			hidden:  []string{"a@b.com", "99999", "Rua X"},
+			visible: []string{`"id":1`, "NICK"},
+		},
+		{
+			name:    "JSON arrays",
+			in:      `[{"first_name": "Ana"}, {"last_name": "Silva"}]`,
+			hidden:  []string{"Ana", "Silva"},
+			visible: []string{redacted},
+		},
+		{
+			name:    "truncated JSON",
+			in:      `{"access_token": "APP_USR-999", "email": "a@b.com", "zip_code": "01001`,
+			hidden:  []string{"APP_USR-999", "a@b.com"},
+			visible: []string{`"email"`},
+		},
+		{
+			name:    "keys are case insensitive",
+			in:      `{"Email": "a@b.com", "Password": "hunter2"}`,
+			hidden:  []string{"a@b.com", "hunter2"},
+			visible: []string{"Email"},
+		},
+		{
+			name:    "plain text without secrets",
+			in:      "pedido 123 sincronizado",
+			visible: []string{"pedido 123 sincronizado"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Redact(tt.in)
+			for _, s := range tt.hidden {
+				if strings.Contains(got, s) {
+					t.Errorf("%q still contains %q", got, s)
+				}
+			}
+			for _, s := range tt.visible {
+				if !strings.Contains(got, s) {
+					t.Errorf("%q lost %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactHandler(t *testing.T) {
+	tests := []struct {
+		name    string
+		log     func(*slog.Logger)
+		hidden  []string
+		visible []string
+	}{
+		{
+			name:    "secret attribute",
+			log:     func(l *slog.Logger) { l.Info("token", "access_token", "APP_USR-1") },
+			hidden:  []string{"APP_USR-1"},
+			visible: []string{"access_token=" + redacted},
+		},
+		{
+			name:    "personal data attributes",
+			log:     func(l *slog.Logger) { l.Info("buyer", "email", "a@b.com", "Phone", "99999", "order_id", 42) },
+			hidden:  []string{"a@b.com", "99999"},
+			visible: []string{"email=" + redacted, "Phone=" + redacted, "order_id=42"},
+		},
+		{
+			name:    "personal data inside a group",
+			log:     func(l *slog.Logger) { l.Info("buyer", slog.Group("buyer", "first_name", "Ana", "id", 7)) },
+			hidden:  []string{"Ana"},
+			visible: []string{"buyer.first_name=" + redacted, "buyer.id=7"},
+		},
+		{
+			name:    "attributes added with With",
+			log:     func(l *slog.Logger) { l.With("doc_number", "12345678900").Info("nota") },
+			hidden:  []string{"12345678900"},
+			visible: []string{"doc_number=" + redacted},
+		},
+		{
+			name:    "error with a token",
+			log:     func(l *slog.Logger) { l.Error("falhou", "error", errors.New("GET /orders?access_token=APP_USR-2")) },
+			hidden:  []string{"APP_USR-2"},
+			visible: []string{"/orders?access_token="},
+		},
+		{
+			name:    "message",
+			log:     func(l *slog.Logger) { l.Info(`resposta {"email": "a@b.com"}`) },
+			hidden:  []string{"a@b.com"},
+			visible: []string{"resposta"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.log(slog.New(&redactHandler{Handler: slog.NewTextHandler(&buf, nil)}))
+			got := buf.String()
+			for _, s := range tt.hidden {
+				if strings.Contains(got, s) {
+					t.Errorf("%q still contains %q", got, s)
+				}
+			}
+			for _, s := range tt.visible {
+				if !strings.Contains(got, s) {
+					t.Errorf("%q lost %q", got, s)
+				}
+			}
+		})
+	}
+}
+
+func TestRedactHandlerWithAttrsKeepsCallerSlice(t *testing.T) {
+	var buf bytes.Buffer
+	h := &redactHandler{Handler: slog.NewTextHandler(&buf, nil)}
+
+	attrs := []slog.Attr{slog.String("email", "a@b.com")}
+	slog.New(h.WithAttrs(attrs)).Info("nota")
+
+	if got := attrs[0].Value.String(); got != "a@b.com" {
+		t.Errorf("caller attrs changed to %q", got)
+	}
+	if got := buf.String(); strings.Contains(got, "a@b.com") {
+		t.Errorf("%q still contains the email", got)
+	}
+}
@@ -3,15 +3,28 @@ package main
 import (
 	"fmt"
 	"log/slog"
+	"os"
+	"strconv"
 	"time"
 
+	"dimi/kkalcs/cogs"
 	"dimi/kkalcs/dotenv"
+	"dimi/kkalcs/fanout"
 	"dimi/kkalcs/logger"
+	"dimi/kkalcs/mlapi"
+	"dimi/kkalcs/mlapi/ads"
 	"dimi/kkalcs/mlapi/auth"
+	"dimi/kkalcs/mlapi/claims"
 	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/payments"
 	"dimi/kkalcs/mlapi/requests"
+	"dimi/kkalcs/mlapi/shipments"
+	"dimi/kkalcs/profit"
 	shpauth "dimi/kkalcs/shpeapi/auth"
 	shporder "dimi/kkalcs/shpeapi/orders"
+	"dimi/kkalcs/store"
+	"dimi/kkalcs/taxes"
+	"dimi/kkalcs/timezone"
 )
 
 type Paging struct {
@@ -25,19 +38,41 @@ type SearchResult struct {
 
 func main() {
 	dotenv.Load()
-	LoadUserId()
 	setupLogger()
-	fmt.Println(shpauth.GetAcessToken())
+
+	if len(os.Args) > 1 {
+		err := runCommand(os.Args[1], os.Args[2:])
+		if err != nil {
+			slog.Error("Error in command execution", "error", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	fmt.Println("Shopee access token:", logger.Mask(shpauth.GetAcessToken()))
 	shporder.Chance()
-	// err := api.Run()
+	// c, err := auth.NewClient()
+	// if err == nil {
+	// 	err = api.Run(c, nil)
+	// }
 	// if err != nil {
 	// 	slog.Error("Error in code execution", "error", err)
 	// }
 }
 
 func run() error {
+	c, err := auth.NewClient()
+	if err != nil {
+		return err
+	}
+
+	db, err := store.Open("kkalcs.db")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
 
-	err := CalculateProfit()
+	err = CalculateProfit(c, db)
 
 	//orders.Get("2000010876085454")
 
@@ -48,22 +83,16 @@ func run() error {
 	return err
 }
 
-func LoadUserId() {
-	requests.USER_ID = auth.GetUserID()
-}
-
-func Test() {
-	urla := "https://api.mercadolibre.com/orders/2000010821544300/discounts"
-
-	body, err := requests.MakeSimpleRequest(requests.GET, urla, nil)
+func Test(c *mlapi.Client) {
+	discounts, err := orders.FetchDiscounts(c, 2000010821544300)
 	if err != nil {
-		fmt.Println("Erro ao fazer requisição:", err)
+		fmt.Println("Erro ao buscar descontos:", err)
 		return
 	}
-	fmt.Println("Corpo da resposta:", string(body))
+	fmt.Println("Descontos do vendedor:", discounts.SellerFunded(), "Descontos do Mercado Livre:", discounts.MeliFunded())
 
-	urla = "https://api.mercadolibre.com/orders/2000010821544300"
-	body, err = requests.MakeSimpleRequest(requests.GET, urla, nil)
+	urla := "https://api.mercadolibre.com/orders/2000010821544300"
+	body, err := c.MakeSimpleRequest(requests.GET, urla, nil)
 	if err != nil {
 		fmt.Println("Erro ao fazer requisição:", err)
 		return
@@ -71,43 +100,197 @@ func Test() {
 	fmt.Println("Corpo da resposta:", string(body))
 }
 
-func CalculateProfit() error {
-	dateFrom := time.Date(2025, time.February, 21, 0, 0, 0, 0, time.UTC)
-	dateTo := time.Date(2025, time.March, 21, 23, 59, 59, 0, time.UTC)
+func CalculateProfit(c *mlapi.Client, db *store.DB) error {
+	dateFrom := timezone.Date(2025, time.February, 21, 0, 0, 0, 0)
+	dateTo := timezone.Date(2025, time.March, 21, 23, 59, 59, 0)
 
-	ords, err := orders.FetchAll(dateFrom, dateTo)
+	ords, err := orders.FetchAll(c, dateFrom, dateTo, orders.DefaultFilter())
 	if err != nil {
 		return fmt.Errorf("erro ao buscar pedidos: %s", err)
 	}
 
-	// shipments_costs := make([]shipments.ShipmentCost, len(ords))
-	// for _, ord := range ords {
-	// 	if ord.Status == "cancelled" {
-	// 		continue
-	// 	}
-	// 	if ord.ShippingID == 0 {
-	// 		fmt.Println("Pedido sem ID de envio:", ord.OrderID)
-	// 		continue
-	// 	}
-	// 	s, err := shipments.FetchCosts(strconv.Itoa(ord.ShippingID))
-	// 	if err != nil {
-	// 		fmt.Println(ord)
-	// 		fmt.Println("Erro:", err, "SHIPMENT_ID: ", ord.ShippingID, " ORDER_ID: ", ord.OrderID)
-	// 		continue
-	// 	}
-	// 	if s.FinalCost != 0 {
-	// 		fmt.Println("Shipment: ", s, "Order ID:", ord.OrderID)
-	// 		continue
-	// 	}
-	// 	shipments_costs = append(shipments_costs, *s)
-	// }
+	for id, err := range orders.AttachDiscounts(c, ords) {
+		fmt.Println("Erro ao buscar descontos:", err, "ORDER_ID: ", id)
+	}
+
+	err = db.UpsertOrders(ords)
+	if err != nil {
+		return fmt.Errorf("erro ao salvar pedidos: %s", err)
+	}
+
+	// Pedidos do mesmo carrinho compartilham o envio, então o custo é buscado por pack.
+	var shippingIDs []string
+	soldAt := make(map[string]time.Time)
+	for _, pack := range orders.GroupPacks(ords) {
+		if pack.ShippingID == 0 {
+			fmt.Println("Pack sem ID de envio:", pack.ID)
+			continue
+		}
+		id := strconv.Itoa(pack.ShippingID)
+		shippingIDs = append(shippingIDs, id)
+		soldAt[id] = pack.SoldAt()
+	}
+
+	// Custos já salvos no banco só são buscados de novo se estiverem desatualizados.
+	cached, err := db.ShipmentCosts(shippingIDs)
+	if err != nil {
+		return fmt.Errorf("erro ao ler custos de envio: %s", err)
+	}
+	missing := shipments.Missing(shippingIDs, cached, soldAt)
+
+	opts := fanout.Options{
+		Workers:  8,
+		Interval: 50 * time.Millisecond,
+		Progress: func(done, total int) {
+			if done%100 == 0 || done == total {
+				slog.Info("Buscando custos de envio", "done", done, "total", total)
+			}
+		},
+	}
+	costs, errs := fanout.Run(missing, opts, func(id string) (*shipments.ShipmentCost, error) {
+		return shipments.FetchCosts(c, id)
+	})
+	for id, err := range errs {
+		fmt.Println("Erro:", err, "SHIPMENT_ID: ", id)
+	}
+
+	fetched := make([]shipments.ShipmentCost, 0, len(costs))
+	for _, s := range costs {
+		fetched = append(fetched, *s)
+	}
+	err = db.UpsertShipmentCosts(fetched)
+	if err != nil {
+		return fmt.Errorf("erro ao salvar custos de envio: %s", err)
+	}
+
+	// Se a nova busca falhou, o custo salvo continua valendo.
+	for _, s := range fetched {
+		cached[s.ShipmentID] = s
+	}
+	shipments_costs := make([]shipments.ShipmentCost, 0, len(cached))
+	for _, s := range cached {
+		shipments_costs = append(shipments_costs, s)
+	}
+
+	cs, err := claims.FetchForOrders(c, ords)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar reclamações: %s", err)
+	}
+	for id, err := range claims.AttachReturns(c, cs) {
+		fmt.Println("Erro ao buscar devolução:", err, "CLAIM_ID: ", id)
+	}
+	err = db.UpsertClaims(cs)
+	if err != nil {
+		return fmt.Errorf("erro ao salvar reclamações: %s", err)
+	}
+	deductions := claims.Deductions(ords, cs, orders.Monthly)
+
+	paysByOrder, payErrs := payments.FetchForOrders(c, ords)
+	for id, err := range payErrs {
+		fmt.Println("Erro ao buscar pagamento:", err, "PAYMENT_ID: ", id)
+	}
+	var pays []payments.Payment
+	for _, ps := range paysByOrder {
+		pays = append(pays, ps...)
+	}
 
 	fmt.Println("Total de pedidos:", len(ords))
-	orders.Total(ords)
+	summary := orders.Total(ords)
+	fmt.Println("Bruto:", summary.Gross, "Tarifas:", summary.SaleFee, "Descontos do vendedor:", summary.SellerDiscount, "Líquido:", summary.Net)
+	fmt.Println("Unidades:", summary.Units, "Ticket médio:", summary.AverageTicket, "Taxa média:", summary.FeeRate)
+	for name, b := range summary.ByListingType {
+		fmt.Println("  Tipo de anúncio:", name, "Pedidos:", b.Orders, "Bruto:", b.Gross, "Taxa média:", b.FeeRate)
+	}
+	shipments.Total(shipments_costs)
+	table, err := loadUnitCosts(db)
+	if err != nil {
+		return err
+	}
+	margins := cogs.Margins(ords, table, orders.Monthly)
+	fmt.Println("CMV:", margins.Total.COGS, "Margem bruta:", margins.Total.GrossMargin, "Margem de contribuição:", margins.Total.Contribution)
+	for period, m := range margins.ByPeriod {
+		fmt.Println("  Período:", period, "Margem bruta:", m.GrossMargin, "Margem de contribuição:", m.Contribution)
+	}
+	if len(margins.MissingSKUs) > 0 {
+		fmt.Println("SKUs sem custo cadastrado:", margins.MissingSKUs)
+	}
+	rules, err := taxes.LoadIfExists(taxes.RulesFile)
+	if err != nil {
+		return fmt.Errorf("erro ao carregar regras de impostos: %s", err)
+	}
+	if rules != nil {
+		tax := rules.Apply(orders.RevenueBySKU(ords))
+		fmt.Println("Impostos:", tax.Tax, "Alíquota efetiva:", tax.EffectiveRate, "Regime:", tax.Regime)
+	}
+	metrics, err := ads.FetchAll(c, dateFrom, dateTo)
+	if err != nil {
+		return fmt.Errorf("erro ao buscar anúncios: %s", err)
+	}
+	err = db.UpsertAdMetrics(metrics)
+	if err != nil {
+		return fmt.Errorf("erro ao salvar métricas de anúncios: %s", err)
+	}
+	attributed := ads.Attribute(ords, metrics)
+	for _, ia := range attributed {
+		if ia.Spend == 0 {
+			continue
+		}
+		fmt.Println("  Item:", ia.ItemID, "Gasto:", ia.Spend, "Margem após ads:", ia.MarginAfterAds, "ACOS:", ia.ACOS, "TACOS:", ia.TACOS)
+	}
+	fmt.Println("Líquido recebido:", payments.NetReceived(pays))
+	for _, day := range payments.CashFlow(pays) {
+		fmt.Println("  Liberação:", day.Date, "Bruto:", day.Gross, "Tarifa ML:", day.MLFee, "Parcelamento:", day.FinancingFee, "Líquido:", day.Net)
+	}
+	fmt.Println("Devoluções e reembolsos:", deductions.Total.Total())
+	for period, d := range deductions.ByPeriod {
+		fmt.Println("  Período:", period, "Reembolsado:", d.Refunded, "Frete de devolução:", d.ReturnShipping, "Reestocado:", d.Restocked)
+	}
+	for sku, d := range deductions.BySKU {
+		fmt.Println("  SKU:", sku, "Reembolsado:", d.Refunded, "Frete de devolução:", d.ReturnShipping, "Reestocado:", d.Restocked)
+	}
+
+	costsByID := make(map[string]shipments.ShipmentCost, len(shipments_costs))
+	for _, s := range shipments_costs {
+		costsByID[s.ShipmentID] = s
+	}
+	ledger := profit.Build(profit.Inputs{
+		Orders:        ords,
+		ShipmentCosts: costsByID,
+		Ads:           attributed,
+		Taxes:         rules,
+		Costs:         table,
+		Claims:        &deductions,
+	})
+	fmt.Println("Lucro líquido:", ledger.Total().Net)
+	for period, t := range ledger.ByPeriod() {
+		fmt.Println("  Período:", period, "Unidades:", t.Units, "Lucro:", t.Net)
+	}
 
 	return nil
 }
 
+// loadUnitCosts importa unit_costs.csv para o banco, se o arquivo existir, e
+// retorna a tabela com todos os custos salvos.
+func loadUnitCosts(db *store.DB) (*cogs.Table, error) {
+	const costsFile = "unit_costs.csv"
+	if _, err := os.Stat(costsFile); err == nil {
+		table, err := cogs.Load(costsFile)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao carregar custos: %s", err)
+		}
+		err = db.UpsertUnitCosts(table.Costs())
+		if err != nil {
+			return nil, fmt.Errorf("erro ao salvar custos: %s", err)
+		}
+	}
+
+	table, err := db.UnitCosts()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler custos: %s", err)
+	}
+	return table, nil
+}
+
 func setupLogger() {
 
 	logger.SetupLogger()
@@ -0,0 +1,169 @@
+package ads
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"dimi/kkalcs/mlapi"
+	"dimi/kkalcs/mlapi/requests"
+	"dimi/kkalcs/timezone"
+)
+
+const pageLimit = 50
+
+type Advertiser struct {
+	AdvertiserID   int64  `json:"advertiser_id"`
+	AdvertiserName string `json:"advertiser_name"`
+	SiteID         string `json:"site_id"`
+}
+
+type Campaign struct {
+	ID         int64   `json:"id"`
+	Name       string  `json:"name"`
+	Status     string  `json:"status"`
+	Budget     float64 `json:"budget"`
+	ACOSTarget float64 `json:"acos_target"`
+}
+
+// DailyMetric é o resultado de um anúncio (item) em um dia.
+type DailyMetric struct {
+	Date       string  `json:"date"`
+	ItemID     string  `json:"item_id"`
+	CampaignID int64   `json:"campaign_id"`
+	Clicks     int     `json:"clicks"`
+	Prints     int     `json:"prints"`
+	Cost       float64 `json:"cost"`
+	// AttributedSales é o valor vendido atribuído ao anúncio (total_amount).
+	AttributedSales float64 `json:"total_amount"`
+	AttributedUnits int     `json:"units_quantity"`
+}
+
+// Advertisers retorna os anunciantes de Product Ads da conta.
+func Advertisers(c *mlapi.Client) ([]Advertiser, error) {
+	url := "https://api.mercadolibre.com/advertising/advertisers?product_id=PADS"
+
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+	}
+
+	var raw struct {
+		Advertisers []Advertiser `json:"advertisers"`
+	}
+	err = json.Unmarshal(body, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+	}
+
+	return raw.Advertisers, nil
+}
+
+func Campaigns(c *mlapi.Client, advertiserID int64) ([]Campaign, error) {
+	var all []Campaign
+
+	for offset := 0; ; offset += pageLimit {
+		url := fmt.Sprintf(
+			"https://api.mercadolibre.com/advertising/advertisers/%d/product_ads/campaigns?limit=%d&offset=%d",
+			advertiserID, pageLimit, offset,
+		)
+
+		body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+		}
+
+		var page struct {
+			Paging struct {
+				Total int `json:"total"`
+			} `json:"paging"`
+			Results []Campaign `json:"results"`
+		}
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+		}
+
+		all = append(all, page.Results...)
+		if len(page.Results) == 0 || len(all) >= page.Paging.Total {
+			break
+		}
+	}
+
+	return all, nil
+}
+
+// DailyItemMetrics busca o custo e as vendas atribuídas de cada anúncio por dia.
+func DailyItemMetrics(c *mlapi.Client, advertiserID int64, dateFrom, dateTo time.Time) ([]DailyMetric, error) {
+	var all []DailyMetric
+
+	for offset := 0; ; offset += pageLimit {
+		params := url.Values{}
+		params.Set("date_from", timezone.In(dateFrom).Format("2006-01-02"))
+		params.Set("date_to", timezone.In(dateTo).Format("2006-01-02"))
+		params.Set("metrics", "clicks,prints,cost,total_amount,units_quantity")
+		params.Set("aggregation_type", "DAILY")
+		params.Set("limit", strconv.Itoa(pageLimit))
+		params.Set("offset", strconv.Itoa(offset))
+
+		url := fmt.Sprintf(
+			"https://api.mercadolibre.com/advertising/advertisers/%d/product_ads/ads/search?%s",
+			advertiserID, params.Encode(),
+		)
+
+		body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+		}
+
+		var page struct {
+			Paging struct {
+				Total int `json:"total"`
+			} `json:"paging"`
+			Results []struct {
+				ItemID     string        `json:"item_id"`
+				CampaignID int64         `json:"campaign_id"`
+				Metrics    []DailyMetric `json:"metrics"`
+			} `json:"results"`
+		}
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+		}
+
+		for _, r := range page.Results {
+			for _, m := range r.Metrics {
+				m.ItemID = r.ItemID
+				m.CampaignID = r.CampaignID
+				all = append(all, m)
+			}
+		}
+
+		if len(page.Results) == 0 || offset+len(page.Results) >= page.Paging.Total {
+			break
+		}
+	}
+
+	return all, nil
+}
+
+// FetchAll busca as métricas diárias de todos os anunciantes da conta.
+func FetchAll(c *mlapi.Client, dateFrom, dateTo time.Time) ([]DailyMetric, error) {
+	advertisers, err := Advertisers(c)
+	if err != nil {
+		return nil, err
+	}
+
+	var all []DailyMetric
+	for _, a := range advertisers {
+		metrics, err := DailyItemMetrics(c, a.AdvertiserID, dateFrom, dateTo)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao buscar métricas do anunciante %d: %s", a.AdvertiserID, err)
+		}
+		all = append(all, metrics...)
+	}
+
+	return all, nil
+}
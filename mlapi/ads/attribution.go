@@ -0,0 +1,81 @@
+package ads
+
+import (
+	"sort"
+
+	"dimi/kkalcs/mlapi/orders"
+)
+
+// ItemAds junta o gasto com anúncios de um item às vendas dele no período.
+type ItemAds struct {
+	ItemID          string  `json:"item_id"`
+	Spend           float64 `json:"spend"`
+	Clicks          int     `json:"clicks"`
+	AttributedSales float64 `json:"attributed_sales"`
+	AttributedUnits int     `json:"attributed_units"`
+	// Revenue, Units e SaleFee vêm de todos os pedidos do item, com ou sem anúncio.
+	Revenue float64 `json:"revenue"`
+	Units   int     `json:"units"`
+	SaleFee float64 `json:"sale_fee"`
+	// MarginAfterAds é a receita menos a tarifa de venda e o gasto com anúncios.
+	MarginAfterAds float64 `json:"margin_after_ads"`
+	// ACOS é o gasto sobre as vendas atribuídas ao anúncio.
+	ACOS float64 `json:"acos"`
+	// TACOS é o gasto sobre a receita total do item.
+	TACOS float64 `json:"tacos"`
+}
+
+// SpendPerUnit é o gasto com anúncio rateado por unidade vendida do item.
+func (i ItemAds) SpendPerUnit() float64 {
+	if i.Units == 0 {
+		return 0
+	}
+	return i.Spend / float64(i.Units)
+}
+
+// Attribute atribui o gasto com anúncios de cada dia aos itens dos pedidos
+// (OrderItem.ItemID) e calcula ACOS, TACOS e a margem depois dos anúncios.
+// Itens com gasto e sem venda também aparecem no resultado.
+func Attribute(ords []orders.Order, metrics []DailyMetric) []ItemAds {
+	byItem := make(map[string]*ItemAds)
+	get := func(id string) *ItemAds {
+		ia, ok := byItem[id]
+		if !ok {
+			ia = &ItemAds{ItemID: id}
+			byItem[id] = ia
+		}
+		return ia
+	}
+
+	for _, m := range metrics {
+		ia := get(m.ItemID)
+		ia.Spend += m.Cost
+		ia.Clicks += m.Clicks
+		ia.AttributedSales += m.AttributedSales
+		ia.AttributedUnits += m.AttributedUnits
+	}
+
+	for _, order := range ords {
+		for _, item := range order.Items {
+			ia := get(item.ItemID)
+			ia.Revenue += item.UnitPrice * float64(item.Quantity)
+			ia.Units += item.Quantity
+			ia.SaleFee += item.Fee()
+		}
+	}
+
+	result := make([]ItemAds, 0, len(byItem))
+	for _, ia := range byItem {
+		ia.MarginAfterAds = ia.Revenue - ia.SaleFee - ia.Spend
+		if ia.AttributedSales > 0 {
+			ia.ACOS = ia.Spend / ia.AttributedSales
+		}
+		if ia.Revenue > 0 {
+			ia.TACOS = ia.Spend / ia.Revenue
+		}
+		result = append(result, *ia)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Spend > result[j].Spend })
+
+	return result
+}
@@ -5,16 +5,18 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	"dimi/kkalcs/dotenv"
+	"dimi/kkalcs/logger"
+	"dimi/kkalcs/mlapi"
 )
 
 type oAuthResponse struct {
@@ -27,55 +29,103 @@ type oAuthResponse struct {
 	RefreshToken   string    `json:"refresh_token"`
 }
 
-var currentAuthResponse *oAuthResponse
+// TokenFile é o arquivo padrão onde o token da conta é salvo.
+const TokenFile = "auth_response.json"
 
-// GetAcessToken retorna o token de acesso atual. Se o token estiver expirado, ele tenta trocá-lo pelo refresh token.
+// TokenSource implementa mlapi.TokenSource para uma conta. Ele guarda o token
+// em memória e no arquivo path, trocando-o pelo refresh token quando expira.
+type TokenSource struct {
+	path string
+
+	mu      sync.Mutex
+	current *oAuthResponse
+}
+
+func NewTokenSource(path string) *TokenSource {
+	return &TokenSource{path: path}
+}
+
+// AccessToken retorna o token de acesso atual.
 // 1. Se não possui um token em memória, tenta pegar o último salvo em disco.
 // 2. Se não conseguir, inicia o fluxo de autenticação pela primeira vez.
 // 3. Se o token estiver expirado, tenta trocá-lo pelo refresh token.
-func GetAcessToken() string {
-	var err error
+func (s *TokenSource) AccessToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return "", err
+	}
+	return s.current.AccessToken, nil
+}
+
+// UserID retorna o user_id da conta dona do token.
+func (s *TokenSource) UserID() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d", s.current.UserID), nil
+}
+
+// NewClient cria o client da conta, usando o user_id do token como seller.
+func (s *TokenSource) NewClient() (*mlapi.Client, error) {
+	userID, err := s.UserID()
+	if err != nil {
+		return nil, err
+	}
+	return mlapi.NewClient(userID, s), nil
+}
+
+// NewClient cria o client da conta autenticada pelo token salvo em TokenFile.
+func NewClient() (*mlapi.Client, error) {
+	return NewTokenSource(TokenFile).NewClient()
+}
 
-	if currentAuthResponse == nil {
-		currentAuthResponse, err = GetSavedTokenFlow()
+// load garante um token válido em s.current. Deve ser chamado com s.mu travado.
+func (s *TokenSource) load() error {
+	if s.current == nil {
+		saved, err := GetSavedTokenFlow(s.path)
 		if err != nil {
-			currentAuthResponse = FirstTimeFlow()
+			saved, err = FirstTimeFlow()
+			if err != nil {
+				return fmt.Errorf("erro ao autenticar: %s", err)
+			}
+			if err := save(s.path, *saved); err != nil {
+				return fmt.Errorf("erro ao salvar token: %s", err)
+			}
 		}
+		s.current = saved
 	}
 
-	if tokenIsExpired() {
-		currentAuthResponse, err = ExchangeRefreshToken(currentAuthResponse.RefreshToken)
+	if s.current.ExpirationDate.Before(time.Now().UTC()) {
+		refreshed, err := ExchangeRefreshToken(s.current.RefreshToken)
 		if err != nil {
-			panic(err)
+			return err
 		}
+		if err := save(s.path, *refreshed); err != nil {
+			return fmt.Errorf("erro ao salvar token: %s", err)
+		}
+		s.current = refreshed
 	}
-
-	return currentAuthResponse.AccessToken
+	return nil
 }
 
-func GetUserID() string {
-	if currentAuthResponse == nil {
-		GetAcessToken()
-		return fmt.Sprintf("%d", currentAuthResponse.UserID)
+func FirstTimeFlow() (*oAuthResponse, error) {
+	if err := SendAuthRequest(); err != nil {
+		return nil, err
 	}
-	return fmt.Sprintf("%d", currentAuthResponse.UserID)
-}
-
-func FirstTimeFlow() *oAuthResponse {
-	SendAuthRequest()
 	temp_token := getTempToken()
 	if temp_token == "" {
-		panic("Token não encontrado.")
-	}
-	authResponse, err := ExchangeCodeForToken(temp_token)
-	if err != nil {
-		panic(err)
+		return nil, errors.New("token não encontrado")
 	}
-	return authResponse
+	return ExchangeCodeForToken(temp_token)
 }
 
-func GetSavedTokenFlow() (*oAuthResponse, error) {
-	authResponse, err := get()
+func GetSavedTokenFlow(path string) (*oAuthResponse, error) {
+	authResponse, err := get(path)
 
 	if err != nil {
 		return nil, err
@@ -88,19 +138,16 @@ func GetSavedTokenFlow() (*oAuthResponse, error) {
 	return authResponse, nil
 }
 
-func save(authCredentials oAuthResponse) {
-	f, err := os.Create("auth_response.json")
+func save(path string, authCredentials oAuthResponse) error {
+	as_json, err := json.MarshalIndent(authCredentials, "", "\t")
 	if err != nil {
-		panic(err)
+		return err
 	}
-	defer f.Close()
-
-	as_json, _ := json.MarshalIndent(authCredentials, "", "\t")
-	f.Write(as_json)
+	return os.WriteFile(path, as_json, 0600)
 }
 
-func get() (*oAuthResponse, error) {
-	f, err := os.Open("auth_response.json")
+func get(path string) (*oAuthResponse, error) {
+	f, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -138,7 +185,7 @@ func ExchangeRefreshToken(refresh_token string) (*oAuthResponse, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, err
+		return nil, logger.RedactError(err)
 	}
 	defer resp.Body.Close()
 
@@ -146,7 +193,7 @@ func ExchangeRefreshToken(refresh_token string) (*oAuthResponse, error) {
 		bodyBytes, _ := io.ReadAll(resp.Body)
 		bodyString := string(bodyBytes)
 
-		return nil, errors.New("Erro ao consumir refresh token: " + resp.Status + " body: " + bodyString)
+		return nil, errors.New("Erro ao consumir refresh token: " + resp.Status + " body: " + logger.Redact(bodyString))
 	}
 
 	jsonParser := json.NewDecoder(resp.Body)
@@ -160,8 +207,6 @@ func ExchangeRefreshToken(refresh_token string) (*oAuthResponse, error) {
 
 	response.ExpirationDate = calculateExpirationDate(response.ExpiresIn)
 
-	save(response)
-
 	return &response, nil
 }
 
@@ -176,7 +221,7 @@ func ExchangeCodeForToken(code string) (*oAuthResponse, error) {
 
 	req, err := http.NewRequest("POST", "https://api.mercadolibre.com/oauth/token", strings.NewReader(data.Encode()))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	req.Header.Add("Accept", "application/json")
@@ -185,7 +230,7 @@ func ExchangeCodeForToken(code string) (*oAuthResponse, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		panic(err)
+		return nil, logger.RedactError(err)
 	}
 	defer resp.Body.Close()
 
@@ -204,34 +249,22 @@ func ExchangeCodeForToken(code string) (*oAuthResponse, error) {
 
 	response.ExpirationDate = calculateExpirationDate(response.ExpiresIn)
 
-	save(response)
-
 	return &response, nil
 }
 
-func tokenIsExpired() bool {
-	if currentAuthResponse == nil {
-		return true
-	}
-	if currentAuthResponse.ExpirationDate.Before(time.Now().UTC()) {
-		return true
-	}
-	return false
-}
-
 func calculateExpirationDate(expiresIn int) time.Time {
 	nowTime := time.Now().UTC()
 	return nowTime.Add(time.Duration(expiresIn) * time.Second)
 }
 
 // Aqui não é possível salvar o código, ele vai apenas pedir pra autenticar no navegador.
-func SendAuthRequest() {
+func SendAuthRequest() error {
 	client_id := dotenv.Get("APP_ID")
 	redirect_uri := dotenv.Get("REDIRECT_URI")
 	state := "12345"
 	authPath := fmt.Sprintf("https://auth.mercadolivre.com.br/authorization?response_type=code&client_id=%s&redirect_uri=%s&state=%s", client_id, redirect_uri, state)
 
-	openbrowser(authPath)
+	return openbrowser(authPath)
 }
 
 func getTempToken() string {
@@ -239,8 +272,11 @@ func getTempToken() string {
 	var url string
 	fmt.Scanln(&url)
 
-	urlParts := strings.Split(url, "?")
-	urlParts = strings.Split(urlParts[1], "&")
+	_, query, found := strings.Cut(url, "?")
+	if !found {
+		return ""
+	}
+	urlParts := strings.Split(query, "&")
 
 	for _, part := range urlParts {
 		if strings.Contains(part, "code=") {
@@ -251,7 +287,7 @@ func getTempToken() string {
 	return ""
 }
 
-func openbrowser(url string) {
+func openbrowser(url string) error {
 	var err error
 
 	switch runtime.GOOS {
@@ -263,6 +299,7 @@ func openbrowser(url string) {
 		err = exec.Command("open", url).Start()
 	}
 	if err != nil {
-		log.Fatal(err)
+		return fmt.Errorf("erro ao abrir o navegador: %s", err)
 	}
+	return nil
 }
@@ -0,0 +1,302 @@
+// Package billing lê os relatórios de faturamento do Mercado Livre: os
+// períodos faturados, o resumo de cada período e o detalhe de cada cobrança.
+package billing
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"dimi/kkalcs/mlapi"
+	"dimi/kkalcs/mlapi/requests"
+	"dimi/kkalcs/period"
+	"dimi/kkalcs/timezone"
+)
+
+const (
+	baseURL   = "https://api.mercadolibre.com/billing/integration"
+	pageLimit = 100
+)
+
+// Period é um período de faturamento. Key identifica o período nas demais
+// chamadas (ex.: "2025-03-01").
+type Period struct {
+	Key            string  `json:"key"`
+	DateFrom       string  `json:"date_from"`
+	DateTo         string  `json:"date_to"`
+	ExpirationDate string  `json:"expiration_date"`
+	Amount         float64 `json:"amount"`
+	UnpaidAmount   float64 `json:"unpaid_amount"`
+}
+
+// Range é a janela de datas do período, no fuso configurado.
+func (p Period) Range() (period.Range, error) {
+	if len(p.DateFrom) < 10 || len(p.DateTo) < 10 {
+		return period.Range{}, fmt.Errorf("período %s sem datas", p.Key)
+	}
+	from, err := timezone.ParseDate(p.DateFrom[:10])
+	if err != nil {
+		return period.Range{}, fmt.Errorf("data inicial inválida no período %s: %s", p.Key, err)
+	}
+	to, err := timezone.ParseDate(p.DateTo[:10])
+	if err != nil {
+		return period.Range{}, fmt.Errorf("data final inválida no período %s: %s", p.Key, err)
+	}
+	return period.Between(from, to), nil
+}
+
+// Line é uma linha do resumo do período, como "Tarifas de venda".
+type Line struct {
+	Type   string  `json:"type"`
+	Label  string  `json:"label"`
+	Amount float64 `json:"amount"`
+}
+
+// Summary é o resumo do período: o total faturado e as cobranças e
+// bonificações agrupadas.
+type Summary struct {
+	Key     string  `json:"key"`
+	Total   float64 `json:"total"`
+	Charges []Line  `json:"charges"`
+	Bonuses []Line  `json:"bonuses"`
+}
+
+// Kind é o componente de custo de uma cobrança, no mesmo recorte usado pelo
+// kkalcs para calcular o lucro.
+type Kind string
+
+const (
+	KindSaleFee  Kind = "sale_fee"
+	KindShipping Kind = "shipping"
+	KindAds      Kind = "ads"
+	KindOther    Kind = "other"
+)
+
+// subTypeKinds mapeia os códigos de detail_sub_type conhecidos. Os demais são
+// classificados pela descrição.
+var subTypeKinds = map[string]Kind{
+	"CV":   KindSaleFee,
+	"CVC":  KindSaleFee,
+	"CXD":  KindShipping,
+	"CFE":  KindShipping,
+	"PADS": KindAds,
+}
+
+// Detail é uma cobrança (ou bonificação) do período, com o pedido, envio e
+// item a que ela se refere, quando houver.
+type Detail struct {
+	DetailID    int64   `json:"detail_id"`
+	Type        string  `json:"type"`     // CHARGE ou BONUS
+	SubType     string  `json:"sub_type"` // ex.: CV (tarifa de venda)
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+	CreatedAt   string  `json:"created_at"`
+	OrderID     int64   `json:"order_id,omitempty"`
+	ShippingID  int64   `json:"shipping_id,omitempty"`
+	ItemID      string  `json:"item_id,omitempty"`
+}
+
+// Kind classifica a cobrança pelo código do subtipo ou, se ele for
+// desconhecido, pela descrição.
+func (d Detail) Kind() Kind {
+	if k, ok := subTypeKinds[d.SubType]; ok {
+		return k
+	}
+
+	desc := strings.ToLower(d.Description)
+	switch {
+	case strings.Contains(desc, "envío") || strings.Contains(desc, "envio") || strings.Contains(desc, "frete"):
+		return KindShipping
+	case strings.Contains(desc, "publicidad") || strings.Contains(desc, "product ads"):
+		return KindAds
+	case strings.Contains(desc, "venta") || strings.Contains(desc, "venda"):
+		return KindSaleFee
+	}
+	return KindOther
+}
+
+// Charged é o valor efetivamente cobrado: bonificações entram negativas.
+func (d Detail) Charged() float64 {
+	if d.Type == "BONUS" {
+		return -d.Amount
+	}
+	return d.Amount
+}
+
+// Periods retorna os últimos limit períodos de faturamento, do mais recente
+// para o mais antigo.
+func Periods(c *mlapi.Client, limit int) ([]Period, error) {
+	params := url.Values{}
+	params.Set("group", "ML")
+	params.Set("document_type", "BILL")
+	params.Set("offset", "0")
+	params.Set("limit", strconv.Itoa(limit))
+
+	body, err := c.MakeSimpleRequest(requests.GET, baseURL+"/monthly/periods?"+params.Encode(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+	}
+
+	var raw struct {
+		Results []struct {
+			Key            string  `json:"key"`
+			ExpirationDate string  `json:"expiration_date"`
+			Amount         float64 `json:"amount"`
+			UnpaidAmount   float64 `json:"unpaid_amount"`
+			Period         struct {
+				DateFrom string `json:"date_from"`
+				DateTo   string `json:"date_to"`
+			} `json:"period"`
+		} `json:"results"`
+	}
+	err = json.Unmarshal(body, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+	}
+
+	periods := make([]Period, 0, len(raw.Results))
+	for _, r := range raw.Results {
+		periods = append(periods, Period{
+			Key:            r.Key,
+			DateFrom:       r.Period.DateFrom,
+			DateTo:         r.Period.DateTo,
+			ExpirationDate: r.ExpirationDate,
+			Amount:         r.Amount,
+			UnpaidAmount:   r.UnpaidAmount,
+		})
+	}
+	return periods, nil
+}
+
+// FindPeriod busca o período pela chave entre os últimos doze. Chave vazia
+// retorna o mais recente.
+func FindPeriod(c *mlapi.Client, key string) (*Period, error) {
+	periods, err := Periods(c, 12)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range periods {
+		if key == "" || p.Key == key {
+			return &p, nil
+		}
+	}
+	if key == "" {
+		return nil, fmt.Errorf("nenhum período de faturamento encontrado")
+	}
+	return nil, fmt.Errorf("período de faturamento %s não encontrado", key)
+}
+
+// FetchSummary busca o resumo do período.
+func FetchSummary(c *mlapi.Client, key string) (*Summary, error) {
+	url := fmt.Sprintf("%s/periods/key/%s/summary/details?group=ML&document_type=BILL", baseURL, url.PathEscape(key))
+
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+	}
+
+	var raw struct {
+		BillIncludes struct {
+			TotalAmount float64 `json:"total_amount"`
+			Charges     []Line  `json:"charges"`
+			Bonuses     []Line  `json:"bonuses"`
+		} `json:"bill_includes"`
+	}
+	err = json.Unmarshal(body, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+	}
+
+	return &Summary{
+		Key:     key,
+		Total:   raw.BillIncludes.TotalAmount,
+		Charges: raw.BillIncludes.Charges,
+		Bonuses: raw.BillIncludes.Bonuses,
+	}, nil
+}
+
+// FetchDetails busca todas as cobranças do período, página por página.
+func FetchDetails(c *mlapi.Client, key string) ([]Detail, error) {
+	var all []Detail
+
+	for offset := 0; ; offset += pageLimit {
+		params := url.Values{}
+		params.Set("document_type", "BILL")
+		params.Set("limit", strconv.Itoa(pageLimit))
+		params.Set("offset", strconv.Itoa(offset))
+
+		url := fmt.Sprintf("%s/periods/key/%s/group/ML/details?%s", baseURL, url.PathEscape(key), params.Encode())
+
+		body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+		}
+
+		details, total, err := extractDetails(body)
+		if err != nil {
+			return nil, err
+		}
+
+		all = append(all, details...)
+		if len(details) == 0 || len(all) >= total {
+			break
+		}
+	}
+
+	return all, nil
+}
+
+func extractDetails(data []byte) ([]Detail, int, error) {
+	var raw struct {
+		Total   int `json:"total"`
+		Results []struct {
+			ChargeInfo struct {
+				DetailID          int64   `json:"detail_id"`
+				DetailType        string  `json:"detail_type"`
+				DetailSubType     string  `json:"detail_sub_type"`
+				TransactionDetail string  `json:"transaction_detail"`
+				DetailAmount      float64 `json:"detail_amount"`
+				CreationDateTime  string  `json:"creation_date_time"`
+			} `json:"charge_info"`
+			SalesInfo []struct {
+				OrderID int64 `json:"order_id"`
+			} `json:"sales_info"`
+			ShippingInfo *struct {
+				ShippingID int64 `json:"shipping_id"`
+			} `json:"shipping_info"`
+			ItemsInfo []struct {
+				ItemID string `json:"item_id"`
+			} `json:"items_info"`
+		} `json:"results"`
+	}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, 0, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+	}
+
+	details := make([]Detail, 0, len(raw.Results))
+	for _, r := range raw.Results {
+		d := Detail{
+			DetailID:    r.ChargeInfo.DetailID,
+			Type:        r.ChargeInfo.DetailType,
+			SubType:     r.ChargeInfo.DetailSubType,
+			Description: r.ChargeInfo.TransactionDetail,
+			Amount:      r.ChargeInfo.DetailAmount,
+			CreatedAt:   r.ChargeInfo.CreationDateTime,
+		}
+		if len(r.SalesInfo) > 0 {
+			d.OrderID = r.SalesInfo[0].OrderID
+		}
+		if r.ShippingInfo != nil {
+			d.ShippingID = r.ShippingInfo.ShippingID
+		}
+		if len(r.ItemsInfo) > 0 {
+			d.ItemID = r.ItemsInfo[0].ItemID
+		}
+		details = append(details, d)
+	}
+
+	return details, raw.Total, nil
+}
@@ -0,0 +1,189 @@
+package billing
+
+import (
+	"math"
+	"sort"
+	"strconv"
+
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/shipments"
+)
+
+// Discrepancy é a diferença entre o que o Mercado Livre cobrou e o que o kkalcs
+// calculou para um pedido. Frete é comparado por envio e atribuído ao primeiro
+// pedido do pack. Product Ads é comparado no período todo, com OrderID zero.
+type Discrepancy struct {
+	OrderID    int64   `json:"order_id"`
+	ShippingID int64   `json:"shipping_id,omitempty"`
+	Kind       Kind    `json:"kind"`
+	Charged    float64 `json:"charged"`
+	Computed   float64 `json:"computed"`
+	Difference float64 `json:"difference"` // Charged - Computed
+	DetailIDs  []int64 `json:"detail_ids"`
+}
+
+// Totals soma o cobrado e o calculado de um componente. Compared é falso para
+// componentes que o kkalcs não calcula, em que Computed não tem significado.
+type Totals struct {
+	Charged  float64 `json:"charged"`
+	Computed float64 `json:"computed"`
+	Compared bool    `json:"compared"`
+}
+
+// Computed é o que o kkalcs calculou para o período de faturamento.
+type Computed struct {
+	Orders []orders.Order
+	// ShipmentCosts indexado pelo ID do envio, como em shipments.ShipmentCost.ShipmentID.
+	ShipmentCosts map[string]shipments.ShipmentCost
+	// AdsSpend é o gasto com Product Ads no período, somando ads.FetchAll.
+	AdsSpend float64
+}
+
+// Report é o resultado da conciliação de um período.
+type Report struct {
+	// Discrepancies vem ordenado da maior diferença absoluta para a menor.
+	Discrepancies []Discrepancy `json:"discrepancies"`
+	// ByKind soma todas as cobranças do período. O calculado só inclui os
+	// pedidos e envios que aparecem nas cobranças.
+	ByKind map[Kind]Totals `json:"by_kind"`
+	// Unmatched são cobranças de venda ou frete cujo pedido ou envio não está
+	// entre os informados.
+	Unmatched []Detail `json:"unmatched"`
+}
+
+// Reconcile compara as cobranças de tarifa de venda, frete e Product Ads com o
+// que foi calculado a partir dos pedidos (OrderItem.Fee), dos custos de envio
+// (ShipmentCost.FinalCost) e do gasto com anúncios. Diferenças acima de
+// tolerance viram Discrepancy. Pedidos sem nenhuma cobrança no período não são
+// comparados, pois podem ter sido faturados em outro período.
+func Reconcile(details []Detail, computed Computed, tolerance float64) Report {
+	report := Report{ByKind: map[Kind]Totals{
+		KindSaleFee:  {Compared: true},
+		KindShipping: {Compared: true},
+		KindAds:      {Compared: true},
+	}}
+	ords, costs := computed.Orders, computed.ShipmentCosts
+
+	byOrder := make(map[int64]orders.Order, len(ords))
+	for _, o := range ords {
+		byOrder[o.OrderID] = o
+	}
+	packByShipping := make(map[int64]orders.Pack)
+	for _, p := range orders.GroupPacks(ords) {
+		if p.ShippingID != 0 {
+			packByShipping[int64(p.ShippingID)] = p
+		}
+	}
+
+	type key struct {
+		id   int64
+		kind Kind
+	}
+	charged := make(map[key]*Discrepancy)
+	var keys []key
+
+	for _, d := range details {
+		kind := d.Kind()
+		t := report.ByKind[kind]
+		t.Charged += d.Charged()
+		report.ByKind[kind] = t
+
+		var k key
+		switch kind {
+		case KindSaleFee:
+			if _, ok := byOrder[d.OrderID]; !ok {
+				report.Unmatched = append(report.Unmatched, d)
+				continue
+			}
+			k = key{d.OrderID, kind}
+		case KindShipping:
+			shippingID := d.ShippingID
+			if shippingID == 0 {
+				shippingID = int64(byOrder[d.OrderID].ShippingID)
+			}
+			if _, ok := packByShipping[shippingID]; !ok {
+				report.Unmatched = append(report.Unmatched, d)
+				continue
+			}
+			k = key{shippingID, kind}
+		default:
+			continue
+		}
+
+		disc, ok := charged[k]
+		if !ok {
+			disc = &Discrepancy{Kind: kind}
+			charged[k] = disc
+			keys = append(keys, k)
+		}
+		disc.Charged += d.Charged()
+		disc.DetailIDs = append(disc.DetailIDs, d.DetailID)
+	}
+
+	for _, k := range keys {
+		disc := charged[k]
+		switch k.kind {
+		case KindSaleFee:
+			disc.OrderID = k.id
+			for _, item := range byOrder[k.id].Items {
+				disc.Computed += item.Fee()
+			}
+		case KindShipping:
+			pack := packByShipping[k.id]
+			disc.OrderID = pack.Orders[0].OrderID
+			disc.ShippingID = k.id
+			disc.Computed = costs[strconv.FormatInt(k.id, 10)].FinalCost
+		}
+
+		t := report.ByKind[k.kind]
+		t.Computed += disc.Computed
+		report.ByKind[k.kind] = t
+
+		disc.Difference = disc.Charged - disc.Computed
+		if math.Abs(disc.Difference) > tolerance {
+			report.Discrepancies = append(report.Discrepancies, *disc)
+		}
+	}
+
+	ads := report.ByKind[KindAds]
+	ads.Computed = computed.AdsSpend
+	report.ByKind[KindAds] = ads
+	if diff := ads.Charged - ads.Computed; math.Abs(diff) > tolerance {
+		report.Discrepancies = append(report.Discrepancies, Discrepancy{
+			Kind:       KindAds,
+			Charged:    ads.Charged,
+			Computed:   ads.Computed,
+			Difference: diff,
+			DetailIDs:  detailIDs(details, KindAds),
+		})
+	}
+
+	sort.SliceStable(report.Discrepancies, func(i, j int) bool {
+		return math.Abs(report.Discrepancies[i].Difference) > math.Abs(report.Discrepancies[j].Difference)
+	})
+
+	return report
+}
+
+// OrderIDs retorna os pedidos referenciados pelas cobranças, sem repetição.
+func OrderIDs(details []Detail) []int64 {
+	seen := make(map[int64]bool)
+	var ids []int64
+	for _, d := range details {
+		if d.OrderID != 0 && !seen[d.OrderID] {
+			seen[d.OrderID] = true
+			ids = append(ids, d.OrderID)
+		}
+	}
+	return ids
+}
+
+func detailIDs(details []Detail, kind Kind) []int64 {
+	var ids []int64
+	for _, d := range details {
+		if d.Kind() == kind {
+			ids = append(ids, d.DetailID)
+		}
+	}
+	return ids
+}
@@ -2,10 +2,12 @@ package categories
 
 import (
 	"container/list"
+	"dimi/kkalcs/mlapi"
 	"dimi/kkalcs/mlapi/requests"
 	"encoding/json"
 	"fmt"
-	"os"
+	"net/url"
+	"strconv"
 )
 
 type Category struct {
@@ -14,9 +16,13 @@ type Category struct {
 }
 
 type ListingPrice struct {
-	ListingTypeID string  `json:"listing_type_id"`
-	SaleFeeAmount float64 `json:"sale_fee_amount"`
-	CurrencyID    string  `json:"currency_id"`
+	ListingTypeID  string  `json:"listing_type_id"`
+	SaleFeeAmount  float64 `json:"sale_fee_amount"`
+	CurrencyID     string  `json:"currency_id"`
+	SaleFeeDetails struct {
+		PercentageFee float64 `json:"percentage_fee"`
+		FixedFee      float64 `json:"fixed_fee"`
+	} `json:"sale_fee_details"`
 }
 
 type SubCategory struct {
@@ -25,10 +31,10 @@ type SubCategory struct {
 	ChildrenCategories []Category `json:"children_categories"`
 }
 
-func GetCategories() ([]Category, error) {
+func GetCategories(c *mlapi.Client) ([]Category, error) {
 	url := "https://api.mercadolibre.com/sites/MLB/categories"
 
-	body, err := requests.MakeSimpleRequest(requests.GET, url, nil)
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
 	}
@@ -42,28 +48,42 @@ func GetCategories() ([]Category, error) {
 	return result, nil
 }
 
-func GetListingPrices(category string) ([]ListingPrice, error) {
-	url := "https://api.mercadolibre.com/sites/MLB/listing_prices?price=59.29&category_id=" + category
-	fmt.Println("URL:", url)
+// GetListingPrices busca as tarifas de venda da categoria para um item vendido
+// por price. Se listingType for informado, apenas a tarifa desse tipo de
+// anúncio é retornada.
+func GetListingPrices(c *mlapi.Client, category string, price float64, listingType string) ([]ListingPrice, error) {
+	params := url.Values{}
+	params.Set("price", strconv.FormatFloat(price, 'f', 2, 64))
+	params.Set("category_id", category)
+	if listingType != "" {
+		params.Set("listing_type_id", listingType)
+	}
+	url := "https://api.mercadolibre.com/sites/MLB/listing_prices?" + params.Encode()
 
-	res, err := requests.MakeRequest(requests.GET, url, nil)
-	defer res.Body.Close()
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+	}
 
+	// Com listing_type_id a API responde um objeto, sem ele uma lista.
 	var prices []ListingPrice
-	if err := json.NewDecoder(res.Body).Decode(&prices); err != nil {
-		return nil, fmt.Errorf("erro ao decodificar resposta: %v", err)
+	if len(body) > 0 && body[0] == '{' {
+		var price ListingPrice
+		err = json.Unmarshal(body, &price)
+		prices = []ListingPrice{price}
+	} else {
+		err = json.Unmarshal(body, &prices)
 	}
-
 	if err != nil {
-		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+		return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
 	}
 
 	return prices, nil
 }
 
-func fetchCategory(categoryID string) (*SubCategory, error) {
+func fetchCategory(c *mlapi.Client, categoryID string) (*SubCategory, error) {
 	url := fmt.Sprintf("https://api.mercadolibre.com/categories/%s", categoryID)
-	body, err := requests.MakeSimpleRequest(requests.GET, url, nil)
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -77,8 +97,8 @@ func fetchCategory(categoryID string) (*SubCategory, error) {
 	return &cat, nil
 }
 
-func PrintCategoryTree(categoryID string, indent string, l *list.List) {
-	subcat, err := fetchCategory(categoryID)
+func PrintCategoryTree(c *mlapi.Client, categoryID string, indent string, l *list.List) {
+	subcat, err := fetchCategory(c, categoryID)
 	if err != nil {
 		fmt.Printf("Erro ao buscar categoria %s: %v\n", categoryID, err)
 		return
@@ -94,12 +114,12 @@ func PrintCategoryTree(categoryID string, indent string, l *list.List) {
 	fmt.Printf("%s- %s (%s)\n", indent, subcat.Name, subcat.ID)
 
 	for _, child := range subcat.ChildrenCategories {
-		PrintCategoryTree(child.ID, indent+"  ", l)
+		PrintCategoryTree(c, child.ID, indent+"  ", l)
 	}
 }
 
-func GetAllCategories() ([]Category, error) {
-	all_cat, err := GetCategories()
+func GetAllCategories(c *mlapi.Client) ([]Category, error) {
+	all_cat, err := GetCategories(c)
 	if err != nil {
 		fmt.Println("Erro ao conseguir categorias: ", err)
 		return nil, err
@@ -108,7 +128,7 @@ func GetAllCategories() ([]Category, error) {
 	l := list.New()
 
 	for _, cat := range all_cat {
-		PrintCategoryTree(cat.ID, "  ", l)
+		PrintCategoryTree(c, cat.ID, "  ", l)
 	}
 
 	arr := make([]Category, l.Len())
@@ -120,53 +140,33 @@ func GetAllCategories() ([]Category, error) {
 	return arr, nil
 }
 
-func SaveCategoriesToFile(categories []Category) error {
-	data, err := json.MarshalIndent(categories, "", "  ")
-	if err != nil {
-		return fmt.Errorf("erro ao serializar categorias: %v", err)
-	}
+// Store guarda as categorias já buscadas, para que a árvore não seja percorrida
+// de novo a cada execução.
+type Store interface {
+	Categories() ([]Category, error)
+	UpsertCategories([]Category) error
+}
 
-	err = os.WriteFile("all_categories.json", data, 0644)
+// LoadOrFetchCategories carrega as categorias do store e, se ele estiver vazio,
+// busca a árvore completa no Mercado Livre e a grava no store.
+func LoadOrFetchCategories(c *mlapi.Client, s Store) ([]Category, error) {
+	cached, err := s.Categories()
 	if err != nil {
-		return fmt.Errorf("erro ao escrever no arquivo: %v", err)
+		return nil, fmt.Errorf("erro ao ler categorias: %v", err)
+	}
+	if len(cached) > 0 {
+		return cached, nil
 	}
 
-	return nil
-}
-
-func LoadCategoriesFromFile() ([]Category, error) {
-	data, err := os.ReadFile("all_categories.json")
+	categories, err := GetAllCategories(c)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao ler o arquivo: %v", err)
+		return nil, fmt.Errorf("erro ao buscar categorias: %v", err)
 	}
 
-	var categories []Category
-	err = json.Unmarshal(data, &categories)
+	err = s.UpsertCategories(categories)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao deserializar categorias: %v", err)
+		return nil, fmt.Errorf("erro ao salvar categorias: %v", err)
 	}
 
 	return categories, nil
 }
-
-func LoadOrFetchCategories() ([]Category, error) {
-	const fileName = "all_categories.json"
-
-	if _, err := os.Stat(fileName); os.IsNotExist(err) {
-		fmt.Println("Arquivo não encontrado. Buscando categorias...")
-		categories, err := GetAllCategories()
-		if err != nil {
-			return nil, fmt.Errorf("erro ao buscar categorias: %v", err)
-		}
-
-		err = SaveCategoriesToFile(categories)
-		if err != nil {
-			return nil, fmt.Errorf("erro ao salvar categorias: %v", err)
-		}
-
-		return categories, nil
-	}
-
-	fmt.Println("Arquivo encontrado. Carregando categorias do disco...")
-	return LoadCategoriesFromFile()
-}
@@ -0,0 +1,334 @@
+package claims
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"dimi/kkalcs/fanout"
+	"dimi/kkalcs/mlapi"
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/requests"
+)
+
+const pageLimit = 30
+
+// Claim é uma reclamação ou mediação aberta sobre um pedido.
+type Claim struct {
+	ID          int64  `json:"id"`
+	Type        string `json:"type"`
+	Stage       string `json:"stage"`
+	Status      string `json:"status"`
+	ResourceID  int64  `json:"resource_id"`
+	Resource    string `json:"resource"`
+	ReasonID    string `json:"reason_id"`
+	DateCreated string `json:"date_created"`
+	LastUpdated string `json:"last_updated"`
+	Resolution  *struct {
+		Reason      string   `json:"reason"`
+		DateCreated string   `json:"date_created"`
+		BenefitedBy []string `json:"benefited"`
+	} `json:"resolution"`
+	Return *Return `json:"return,omitempty"`
+}
+
+// OrderID é o pedido da reclamação. Só é válido quando o recurso é um pedido.
+func (c Claim) OrderID() int64 {
+	if c.Resource != "order" {
+		return 0
+	}
+	return c.ResourceID
+}
+
+type ReturnItem struct {
+	ItemID   string `json:"item_id"`
+	Quantity int    `json:"quantity"`
+}
+
+type ReturnShipment struct {
+	ShipmentID int64  `json:"shipment_id"`
+	Status     string `json:"status"`
+	Type       string `json:"type"`
+	// Cost é o frete de devolução cobrado do vendedor.
+	Cost float64 `json:"cost"`
+}
+
+// Return é a devolução associada a uma reclamação.
+type Return struct {
+	ID            int64            `json:"id"`
+	Status        string           `json:"status"`
+	StatusMoney   string           `json:"status_money"`
+	RefundAt      string           `json:"refund_at"`
+	DateCreated   string           `json:"date_created"`
+	DateClosed    string           `json:"date_closed"`
+	Shipments     []ReturnShipment `json:"shipments"`
+	Orders        []ReturnItem     `json:"orders"`
+	RefundedTotal float64          `json:"refunded_total"`
+}
+
+// Restocked informa se os itens voltaram para o vendedor e podem ser revendidos.
+func (r Return) Restocked() bool {
+	return r.Status == "delivered" || r.Status == "closed"
+}
+
+// FetchAll busca as reclamações do vendedor abertas entre dateFrom e dateTo.
+func FetchAll(c *mlapi.Client, dateFrom, dateTo time.Time) ([]Claim, error) {
+	sellerID, err := c.Seller()
+	if err != nil {
+		return nil, err
+	}
+
+	var all []Claim
+
+	for offset := 0; ; offset += pageLimit {
+		params := url.Values{}
+		params.Set("player_role", "respondent")
+		params.Set("player_user_id", sellerID)
+		params.Set("range", fmt.Sprintf("date_created:after:%s,before:%s", dateFrom.Format(orders.APIDateLayout), dateTo.Format(orders.APIDateLayout)))
+		params.Set("limit", strconv.Itoa(pageLimit))
+		params.Set("offset", strconv.Itoa(offset))
+
+		url := "https://api.mercadolibre.com/post-purchase/v1/claims/search?" + params.Encode()
+		body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+		}
+
+		var page struct {
+			Paging struct {
+				Total int `json:"total"`
+			} `json:"paging"`
+			Data []Claim `json:"data"`
+		}
+		err = json.Unmarshal(body, &page)
+		if err != nil {
+			return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+		}
+
+		all = append(all, page.Data...)
+		if len(page.Data) == 0 || len(all) >= page.Paging.Total {
+			break
+		}
+	}
+
+	return all, nil
+}
+
+// Get busca uma reclamação pelo ID.
+func Get(c *mlapi.Client, claimID int64) (*Claim, error) {
+	url := fmt.Sprintf("https://api.mercadolibre.com/post-purchase/v1/claims/%d", claimID)
+
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+	}
+
+	var claim Claim
+	err = json.Unmarshal(body, &claim)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+	}
+
+	return &claim, nil
+}
+
+// FetchForOrders busca as reclamações dos pedidos. A busca vai da criação do
+// pedido mais antigo até agora, para que devoluções abertas depois do período
+// dos pedidos ainda entrem nas deduções dele.
+func FetchForOrders(c *mlapi.Client, ords []orders.Order) ([]Claim, error) {
+	if len(ords) == 0 {
+		return nil, nil
+	}
+
+	from := ords[0].DateCreated
+	wanted := make(map[int64]bool, len(ords))
+	for _, o := range ords {
+		wanted[o.OrderID] = true
+		if o.DateCreated.Before(from) {
+			from = o.DateCreated
+		}
+	}
+
+	all, err := FetchAll(c, from, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var cs []Claim
+	for _, claim := range all {
+		if wanted[claim.OrderID()] {
+			cs = append(cs, claim)
+		}
+	}
+	return cs, nil
+}
+
+// FetchReturn busca a devolução da reclamação. Reclamações sem devolução retornam nil.
+func FetchReturn(c *mlapi.Client, claimID int64) (*Return, error) {
+	url := fmt.Sprintf("https://api.mercadolibre.com/post-purchase/v2/claims/%d/returns", claimID)
+
+	resp, err := c.MakeRequest(requests.GET, url, nil)
+	if err != nil {
+		// Reclamações sem devolução respondem 404.
+		var statusErr *requests.StatusError
+		if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var ret Return
+	err = json.NewDecoder(resp.Body).Decode(&ret)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao decodificar resposta: %v", err)
+	}
+
+	return &ret, nil
+}
+
+// AttachReturns busca as devoluções das reclamações em paralelo e as guarda em Claim.Return.
+func AttachReturns(c *mlapi.Client, cs []Claim) fanout.Errors[int64] {
+	ids := make([]int64, len(cs))
+	for i, claim := range cs {
+		ids[i] = claim.ID
+	}
+
+	opts := fanout.Options{Workers: 8, Interval: 50 * time.Millisecond}
+	returns, errs := fanout.Run(ids, opts, func(id int64) (*Return, error) {
+		return FetchReturn(c, id)
+	})
+
+	for i := range cs {
+		cs[i].Return = returns[cs[i].ID]
+	}
+
+	return errs
+}
+
+// ByOrder indexa as reclamações pelo ID do pedido.
+func ByOrder(cs []Claim) map[int64][]Claim {
+	byOrder := make(map[int64][]Claim)
+	for _, claim := range cs {
+		if id := claim.OrderID(); id != 0 {
+			byOrder[id] = append(byOrder[id], claim)
+		}
+	}
+	return byOrder
+}
+
+// Deduction é o que as devoluções e reembolsos tiram do lucro.
+type Deduction struct {
+	Claims         int     `json:"claims"`
+	Refunded       float64 `json:"refunded"`
+	ReturnShipping float64 `json:"return_shipping"`
+	Restocked      int     `json:"restocked"`
+}
+
+func (d Deduction) Total() float64 {
+	return d.Refunded + d.ReturnShipping
+}
+
+func (d *Deduction) add(o Deduction) {
+	d.Claims += o.Claims
+	d.Refunded += o.Refunded
+	d.ReturnShipping += o.ReturnShipping
+	d.Restocked += o.Restocked
+}
+
+// Report agrupa as deduções por período e por SKU.
+type Report struct {
+	Total    Deduction            `json:"total"`
+	ByPeriod map[string]Deduction `json:"by_period"`
+	BySKU    map[string]Deduction `json:"by_sku"`
+	ByOrder  map[int64]Deduction  `json:"by_order"`
+	// RestockedByOrder são as unidades que voltaram ao estoque, por pedido e
+	// por ID do anúncio.
+	RestockedByOrder map[int64]map[string]int `json:"restocked_by_order"`
+}
+
+// Deductions cruza as reclamações com os pedidos e calcula, por pedido, o valor
+// reembolsado, o frete de devolução pago pelo vendedor e as unidades que voltaram
+// ao estoque. O período de cada dedução é dado por period a partir da data do pedido.
+func Deductions(ords []orders.Order, cs []Claim, period func(orders.Order) string) Report {
+	report := Report{
+		ByPeriod: make(map[string]Deduction),
+		BySKU:    make(map[string]Deduction),
+		ByOrder:  make(map[int64]Deduction),
+
+		RestockedByOrder: make(map[int64]map[string]int),
+	}
+
+	byOrder := ByOrder(cs)
+	for _, order := range ords {
+		claims, ok := byOrder[order.OrderID]
+		if !ok {
+			continue
+		}
+
+		var d Deduction
+		restockedByItem := make(map[string]int)
+		for _, claim := range claims {
+			d.Claims++
+			if claim.Return == nil {
+				continue
+			}
+			for _, s := range claim.Return.Shipments {
+				d.ReturnShipping += s.Cost
+			}
+			if claim.Return.Restocked() {
+				for _, it := range claim.Return.Orders {
+					restockedByItem[it.ItemID] += it.Quantity
+					d.Restocked += it.Quantity
+				}
+			}
+		}
+		for _, p := range order.Payments {
+			d.Refunded += p.TransactionAmountRefunded
+		}
+
+		report.Total.add(d)
+		report.ByOrder[order.OrderID] = d
+		if len(restockedByItem) > 0 {
+			report.RestockedByOrder[order.OrderID] = restockedByItem
+		}
+		key := period(order)
+		pd := report.ByPeriod[key]
+		pd.add(d)
+		report.ByPeriod[key] = pd
+
+		// Reparte a dedução do pedido entre os SKUs pelo valor bruto de cada item.
+		var gross float64
+		for _, item := range order.Items {
+			gross += item.UnitPrice * float64(item.Quantity)
+		}
+		// Reclamações e unidades reestocadas não são rateadas: contam uma vez
+		// por SKU e por item, mesmo que ele apareça em mais de uma linha.
+		countedSKU := make(map[string]bool)
+		countedItem := make(map[string]bool)
+		for _, item := range order.Items {
+			share := 1 / float64(len(order.Items))
+			if gross > 0 {
+				share = item.UnitPrice * float64(item.Quantity) / gross
+			}
+			sd := report.BySKU[item.SKU]
+			if !countedSKU[item.SKU] {
+				countedSKU[item.SKU] = true
+				sd.Claims += d.Claims
+			}
+			if !countedItem[item.ItemID] {
+				countedItem[item.ItemID] = true
+				sd.Restocked += restockedByItem[item.ItemID]
+			}
+			sd.Refunded += d.Refunded * share
+			sd.ReturnShipping += d.ReturnShipping * share
+			report.BySKU[item.SKU] = sd
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,64 @@
+package mlapi
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"dimi/kkalcs/mlapi/requests"
+)
+
+// TokenSource fornece o access token usado nas requisições de uma conta.
+type TokenSource interface {
+	AccessToken() (string, error)
+}
+
+// Client representa uma conta de vendedor do Mercado Livre. Todos os pacotes do
+// mlapi recebem o client explicitamente, então várias contas podem coexistir.
+type Client struct {
+	SellerID string
+	Tokens   TokenSource
+	HTTP     *http.Client
+}
+
+var ErrMissingSeller = errors.New("client sem seller ID")
+
+func NewClient(sellerID string, tokens TokenSource) *Client {
+	return &Client{
+		SellerID: sellerID,
+		Tokens:   tokens,
+		HTTP:     &http.Client{},
+	}
+}
+
+// Seller retorna o ID do vendedor, ou erro se o client não tiver um.
+func (c *Client) Seller() (string, error) {
+	if c.SellerID == "" {
+		return "", ErrMissingSeller
+	}
+	return c.SellerID, nil
+}
+
+func (c *Client) MakeRequest(method requests.Method, url string, body *bytes.Buffer) (*http.Response, error) {
+	token, err := c.Tokens.AccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter access token: %s", err)
+	}
+	return requests.MakeRequest(c.httpClient(), token, method, url, body)
+}
+
+func (c *Client) MakeSimpleRequest(method requests.Method, url string, body *bytes.Buffer) ([]byte, error) {
+	token, err := c.Tokens.AccessToken()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao obter access token: %s", err)
+	}
+	return requests.MakeSimpleRequest(c.httpClient(), token, method, url, body)
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTP == nil {
+		return http.DefaultClient
+	}
+	return c.HTTP
+}
@@ -0,0 +1,75 @@
+package items
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"dimi/kkalcs/mlapi"
+)
+
+type Attribute struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	ValueName string `json:"value_name"`
+}
+
+type Item struct {
+	ID            string      `json:"id"`
+	Title         string      `json:"title"`
+	Thumbnail     string      `json:"thumbnail"`
+	CategoryID    string      `json:"category_id"`
+	ListingTypeID string      `json:"listing_type_id"`
+	Price         float64     `json:"price"`
+	Status        string      `json:"status"`
+	Permalink     string      `json:"permalink"`
+	Attributes    []Attribute `json:"attributes"`
+}
+
+// Result guarda o item de um ID ou o erro ao buscá-lo.
+type Result struct {
+	ID   string
+	Item *Item
+	Err  error
+}
+
+// GetMany busca os itens em lotes pelo multiget de /items. Se attributes for
+// informado, apenas esses campos são retornados pela API.
+func GetMany(c *mlapi.Client, ids []string, attributes ...string) []Result {
+	raw := c.MultiGet("https://api.mercadolibre.com/items", ids, attributes)
+
+	results := make([]Result, len(raw))
+	for i, r := range raw {
+		results[i].ID = r.ID
+		if r.Err != nil {
+			results[i].Err = r.Err
+			continue
+		}
+
+		var item Item
+		err := json.Unmarshal(r.Body, &item)
+		if err != nil {
+			results[i].Err = fmt.Errorf("erro ao fazer unmarshal: %v", err)
+			continue
+		}
+		results[i].Item = &item
+	}
+
+	return results
+}
+
+// GetMap é como GetMany, mas devolve apenas os itens encontrados indexados por ID.
+// Os IDs que falharam são retornados em errs.
+func GetMap(c *mlapi.Client, ids []string, attributes ...string) (map[string]Item, map[string]error) {
+	found := make(map[string]Item)
+	errs := make(map[string]error)
+
+	for _, r := range GetMany(c, ids, attributes...) {
+		if r.Err != nil {
+			errs[r.ID] = r.Err
+			continue
+		}
+		found[r.ID] = *r.Item
+	}
+
+	return found, errs
+}
@@ -0,0 +1,94 @@
+package mlapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+
+	"dimi/kkalcs/fanout"
+	"dimi/kkalcs/mlapi/requests"
+)
+
+// MultiGetLimit é a quantidade máxima de IDs aceita pelos endpoints multiget
+// do Mercado Livre (/items?ids=, /users?ids=).
+const MultiGetLimit = 20
+
+// multiGetWorkers limita quantos lotes são buscados ao mesmo tempo.
+const multiGetWorkers = 4
+
+type MultiGetResult struct {
+	ID   string
+	Code int
+	Body json.RawMessage
+	Err  error
+}
+
+// MultiGet busca os recursos de baseURL em lotes de até MultiGetLimit IDs, executando
+// os lotes em paralelo. O resultado mantém a ordem de ids e cada ID carrega seu
+// próprio erro, seja da requisição do lote ou do código retornado para ele.
+func (c *Client) MultiGet(baseURL string, ids []string, attributes []string) []MultiGetResult {
+	results := make([]MultiGetResult, len(ids))
+
+	if len(attributes) > 0 && !slices.Contains(attributes, "id") {
+		attributes = append([]string{"id"}, attributes...)
+	}
+
+	var starts []int
+	for start := 0; start < len(ids); start += MultiGetLimit {
+		starts = append(starts, start)
+	}
+
+	fanout.Run(starts, fanout.Options{Workers: multiGetWorkers}, func(start int) (struct{}, error) {
+		end := min(start+MultiGetLimit, len(ids))
+		c.fetchBatch(baseURL, ids[start:end], attributes, results[start:end])
+		return struct{}{}, nil
+	})
+
+	return results
+}
+
+func (c *Client) fetchBatch(baseURL string, ids []string, attributes []string, results []MultiGetResult) {
+	params := url.Values{}
+	params.Set("ids", strings.Join(ids, ","))
+	if len(attributes) > 0 {
+		params.Set("attributes", strings.Join(attributes, ","))
+	}
+
+	for i, id := range ids {
+		results[i].ID = id
+	}
+
+	body, err := c.MakeSimpleRequest(requests.GET, baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		for i := range results {
+			results[i].Err = err
+		}
+		return
+	}
+
+	var raw []struct {
+		Code int             `json:"code"`
+		Body json.RawMessage `json:"body"`
+	}
+	err = json.Unmarshal(body, &raw)
+	if err != nil {
+		for i := range results {
+			results[i].Err = fmt.Errorf("erro ao fazer unmarshal: %v", err)
+		}
+		return
+	}
+
+	for i := range results {
+		if i >= len(raw) {
+			results[i].Err = fmt.Errorf("ID %s ausente na resposta", results[i].ID)
+			continue
+		}
+		results[i].Code = raw[i].Code
+		results[i].Body = raw[i].Body
+		if raw[i].Code != 200 {
+			results[i].Err = fmt.Errorf("error: status code %d: %s", raw[i].Code, string(raw[i].Body))
+		}
+	}
+}
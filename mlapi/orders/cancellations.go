@@ -0,0 +1,110 @@
+package orders
+
+// CancellationReport resume o impacto dos pedidos cancelados e dos reembolsos
+// de pedidos que não foram cancelados.
+type CancellationReport struct {
+	CancelledOrders int     `json:"cancelled_orders"`
+	LostRevenue     float64 `json:"lost_revenue"`
+	// FeesCharged é a tarifa de venda dos pedidos cancelados. Ela se divide em
+	// FeesRefunded, quando o pagamento foi devolvido ou nunca aprovado, e FeesKept,
+	// quando ainda há pagamento aprovado e o Mercado Livre ficou com a tarifa.
+	FeesCharged  float64 `json:"fees_charged"`
+	FeesRefunded float64 `json:"fees_refunded"`
+	FeesKept     float64 `json:"fees_kept"`
+
+	// PartiallyRefunded conta os pedidos em que só parte do pagamento foi
+	// devolvida; FullyRefunded, os devolvidos por inteiro sem cancelamento.
+	PartiallyRefundedOrders int     `json:"partially_refunded_orders"`
+	PartiallyRefundedAmount float64 `json:"partially_refunded_amount"`
+	FullyRefundedOrders     int     `json:"fully_refunded_orders"`
+	FullyRefundedAmount     float64 `json:"fully_refunded_amount"`
+
+	// ByRequester conta os cancelamentos por quem pediu (buyer, seller, ...).
+	ByRequester map[string]int `json:"by_requester"`
+	// ByReason conta os cancelamentos pelo código do motivo.
+	ByReason map[string]int `json:"by_reason"`
+}
+
+// CancellationFilter busca pelos pedidos pagos, confirmados e cancelados, como
+// Cancellations espera.
+func CancellationFilter() Filter {
+	return Filter{
+		Statuses:  []string{"paid", "confirmed", "cancelled"},
+		DateField: DateCreated,
+	}
+}
+
+// Cancellations gera o relatório a partir dos pedidos, que devem ter sido buscados
+// com um Filter que inclua o status "cancelled", como CancellationFilter.
+func Cancellations(orders []Order) CancellationReport {
+	report := CancellationReport{
+		ByRequester: make(map[string]int),
+		ByReason:    make(map[string]int),
+	}
+
+	for _, order := range orders {
+		if order.Status != "cancelled" {
+			refunded, paid := order.refundedAmount()
+			switch {
+			case refunded <= 0:
+			case refunded < paid:
+				report.PartiallyRefundedOrders++
+				report.PartiallyRefundedAmount += refunded
+			default:
+				report.FullyRefundedOrders++
+				report.FullyRefundedAmount += refunded
+			}
+			continue
+		}
+
+		report.CancelledOrders++
+
+		var fee float64
+		for _, item := range order.Items {
+			report.LostRevenue += item.UnitPrice * float64(item.Quantity)
+			fee += item.Fee()
+		}
+		report.FeesCharged += fee
+
+		if order.hasApprovedPayment() {
+			report.FeesKept += fee
+		} else {
+			report.FeesRefunded += fee
+		}
+
+		requester, reason := "unknown", "unknown"
+		if order.CancelDetail != nil {
+			if order.CancelDetail.RequestedBy != "" {
+				requester = order.CancelDetail.RequestedBy
+			}
+			if order.CancelDetail.Code != "" {
+				reason = order.CancelDetail.Code
+			}
+		}
+		report.ByRequester[requester]++
+		report.ByReason[reason]++
+	}
+
+	return report
+}
+
+func (o Order) hasApprovedPayment() bool {
+	for _, p := range o.Payments {
+		if p.Status == "approved" && p.TransactionAmountRefunded < p.TransactionAmount {
+			return true
+		}
+	}
+	return false
+}
+
+// refundedAmount soma o que já foi devolvido ao comprador e o valor das
+// transações dos pagamentos aprovados ou devolvidos do pedido.
+func (o Order) refundedAmount() (refunded, paid float64) {
+	for _, p := range o.Payments {
+		refunded += p.TransactionAmountRefunded
+		if p.Status == "approved" || p.Status == "refunded" {
+			paid += p.TransactionAmount
+		}
+	}
+	return refunded, paid
+}
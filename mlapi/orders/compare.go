@@ -0,0 +1,104 @@
+package orders
+
+import "math"
+
+// Delta é a variação de uma métrica entre o período atual e o de comparação.
+type Delta struct {
+	Current  float64 `json:"current"`
+	Previous float64 `json:"previous"`
+	// Change é Current - Previous.
+	Change float64 `json:"change"`
+	// Percent é Change / |Previous|, ou nil se Previous for zero. O módulo
+	// mantém o sinal da variação quando o valor anterior é negativo.
+	Percent *float64 `json:"percent"`
+}
+
+// NewDelta calcula a variação de previous para current.
+func NewDelta(current, previous float64) Delta {
+	d := Delta{Current: current, Previous: previous, Change: current - previous}
+	if previous != 0 {
+		p := d.Change / math.Abs(previous)
+		d.Percent = &p
+	}
+	return d
+}
+
+// BreakdownDelta compara dois Breakdown. NetMargin é Net / Gross: a margem
+// depois das tarifas e dos descontos do vendedor, sem o CMV. A margem com o
+// custo da mercadoria é comparada por cogs.Compare.
+type BreakdownDelta struct {
+	Orders        Delta `json:"orders"`
+	Units         Delta `json:"units"`
+	Gross         Delta `json:"gross"`
+	SaleFee       Delta `json:"sale_fee"`
+	Net           Delta `json:"net"`
+	NetMargin     Delta `json:"net_margin"`
+	AverageTicket Delta `json:"average_ticket"`
+}
+
+func compareBreakdown(current, previous Breakdown) BreakdownDelta {
+	return BreakdownDelta{
+		Orders:        NewDelta(float64(current.Orders), float64(previous.Orders)),
+		Units:         NewDelta(float64(current.Units), float64(previous.Units)),
+		Gross:         NewDelta(current.Gross, previous.Gross),
+		SaleFee:       NewDelta(current.SaleFee, previous.SaleFee),
+		Net:           NewDelta(current.Net, previous.Net),
+		NetMargin:     NewDelta(current.netMargin(), previous.netMargin()),
+		AverageTicket: NewDelta(current.AverageTicket, previous.AverageTicket),
+	}
+}
+
+func (b Breakdown) netMargin() float64 {
+	if b.Gross == 0 {
+		return 0
+	}
+	return b.Net / b.Gross
+}
+
+// Comparison é a variação do resumo entre dois períodos. SKUs e categorias
+// que só aparecem em um dos períodos entram com zero no outro.
+type Comparison struct {
+	Total      BreakdownDelta            `json:"total"`
+	BySKU      map[string]BreakdownDelta `json:"by_sku"`
+	ByCategory map[string]BreakdownDelta `json:"by_category"`
+}
+
+// Compare calcula a variação de current em relação a previous.
+func Compare(current, previous Summary) Comparison {
+	return Comparison{
+		Total:      compareBreakdown(current.Breakdown, previous.Breakdown),
+		BySKU:      compareMap(current.BySKU, previous.BySKU),
+		ByCategory: compareMap(current.ByCategory, previous.ByCategory),
+	}
+}
+
+func compareMap(current, previous map[string]Breakdown) map[string]BreakdownDelta {
+	deltas := make(map[string]BreakdownDelta, len(current))
+	for key, b := range current {
+		deltas[key] = compareBreakdown(b, previous[key])
+	}
+	for key, b := range previous {
+		if _, ok := current[key]; !ok {
+			deltas[key] = compareBreakdown(Breakdown{}, b)
+		}
+	}
+	return deltas
+}
+
+// PeriodComparison é a comparação com um dos períodos de um ComparisonReport.
+type PeriodComparison struct {
+	Period string `json:"period"`
+	Comparison
+}
+
+// ComparisonReport é o resumo de um período comparado a outros.
+type ComparisonReport struct {
+	Period  string             `json:"period"`
+	Summary Summary            `json:"summary"`
+	Against []PeriodComparison `json:"against"`
+}
+
+// Add compara o resumo do relatório com o de outro período.
+func (r *ComparisonReport) Add(period string, previous Summary) {
+	r.Against = append(r.Against, PeriodComparison{Period: period, Comparison: Compare(r.Summary, previous)})
+}
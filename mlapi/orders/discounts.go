@@ -0,0 +1,148 @@
+package orders
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"dimi/kkalcs/fanout"
+	"dimi/kkalcs/mlapi"
+	"dimi/kkalcs/mlapi/requests"
+)
+
+type DiscountAmounts struct {
+	Total  float64 `json:"total"`
+	Full   float64 `json:"full"`
+	Seller float64 `json:"seller"`
+}
+
+type DiscountItem struct {
+	ID       string          `json:"id"`
+	Quantity int             `json:"quantity"`
+	Amounts  DiscountAmounts `json:"amounts"`
+}
+
+type DiscountSupplier struct {
+	OfferID     string `json:"offer_id"`
+	FundingMode string `json:"funding_mode"`
+}
+
+// DiscountDetail é um desconto aplicado ao pedido (cupom, campanha, etc.).
+type DiscountDetail struct {
+	Type     string           `json:"type"`
+	Value    float64          `json:"value"`
+	Supplier DiscountSupplier `json:"supplier"`
+	Items    []DiscountItem   `json:"items"`
+}
+
+// Discounts são os descontos do pedido. Em amounts, seller é a parte bancada pelo
+// vendedor; o restante do total é bancado pelo Mercado Livre.
+type Discounts struct {
+	Details []DiscountDetail `json:"details"`
+}
+
+// SellerFunded é o valor dos descontos pago pelo vendedor, que reduz a receita.
+func (d Discounts) SellerFunded() float64 {
+	var total float64
+	for _, detail := range d.Details {
+		for _, item := range detail.Items {
+			total += item.Amounts.Seller
+		}
+	}
+	return total
+}
+
+// MeliFunded é o valor dos descontos pago pelo Mercado Livre.
+func (d Discounts) MeliFunded() float64 {
+	var total float64
+	for _, detail := range d.Details {
+		for _, item := range detail.Items {
+			total += item.Amounts.Total - item.Amounts.Seller
+		}
+	}
+	return total
+}
+
+// SellerFundedByItem é como SellerFunded, mas separado por ID do anúncio.
+func (d Discounts) SellerFundedByItem() map[string]float64 {
+	byItem := make(map[string]float64)
+	for _, detail := range d.Details {
+		for _, item := range detail.Items {
+			byItem[item.ID] += item.Amounts.Seller
+		}
+	}
+	return byItem
+}
+
+// SellerDiscountByLine reparte os descontos bancados pelo vendedor entre os
+// itens do pedido, na ordem de Items. O desconto vem por anúncio, sem a
+// variação, então itens do mesmo anúncio dividem o valor pelo bruto de cada um.
+func (o Order) SellerDiscountByLine() []float64 {
+	lines := make([]float64, len(o.Items))
+	if o.Discounts == nil {
+		return lines
+	}
+
+	gross := make(map[string]float64)
+	count := make(map[string]int)
+	for _, item := range o.Items {
+		gross[item.ItemID] += item.UnitPrice * float64(item.Quantity)
+		count[item.ItemID]++
+	}
+
+	byItem := o.Discounts.SellerFundedByItem()
+	for i, item := range o.Items {
+		share := 1 / float64(count[item.ItemID])
+		if g := gross[item.ItemID]; g > 0 {
+			share = item.UnitPrice * float64(item.Quantity) / g
+		}
+		lines[i] = byItem[item.ItemID] * share
+	}
+	return lines
+}
+
+func FetchDiscounts(c *mlapi.Client, orderID int64) (*Discounts, error) {
+	url := fmt.Sprintf("https://api.mercadolibre.com/orders/%d/discounts", orderID)
+
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+	}
+
+	var discounts Discounts
+	err = json.Unmarshal(body, &discounts)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+	}
+
+	return &discounts, nil
+}
+
+// AttachDiscounts busca os descontos de cada pedido em paralelo e os guarda em
+// Order.Discounts. Os pedidos que falharam ficam sem descontos e voltam em errs.
+func AttachDiscounts(c *mlapi.Client, ords []Order) fanout.Errors[int64] {
+	ids := make([]int64, len(ords))
+	for i, order := range ords {
+		ids[i] = order.OrderID
+	}
+
+	opts := fanout.Options{Workers: 8, Interval: 50 * time.Millisecond}
+	discounts, errs := fanout.Run(ids, opts, func(id int64) (*Discounts, error) {
+		return FetchDiscounts(c, id)
+	})
+
+	for i := range ords {
+		if d, ok := discounts[ords[i].OrderID]; ok {
+			ords[i].Discounts = d
+		}
+	}
+
+	return errs
+}
+
+func (o Order) sellerDiscount() float64 {
+	if o.Discounts == nil {
+		return 0
+	}
+	return o.Discounts.SellerFunded()
+}
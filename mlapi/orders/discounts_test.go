@@ -0,0 +1,79 @@
+package orders
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSellerDiscountByLine(t *testing.T) {
+	discount := func(itemID string, seller float64) DiscountDetail {
+		return DiscountDetail{Items: []DiscountItem{{ID: itemID, Amounts: DiscountAmounts{Seller: seller}}}}
+	}
+
+	tests := []struct {
+		name      string
+		items     []OrderItem
+		discounts *Discounts
+		want      []float64
+	}{
+		{
+			name:  "no discounts",
+			items: []OrderItem{{ItemID: "MLB1", Quantity: 1, UnitPrice: 100}},
+			want:  []float64{0},
+		},
+		{
+			name: "one line per item",
+			items: []OrderItem{
+				{ItemID: "MLB1", Quantity: 1, UnitPrice: 100},
+				{ItemID: "MLB2", Quantity: 1, UnitPrice: 50},
+			},
+			discounts: &Discounts{Details: []DiscountDetail{discount("MLB1", 10), discount("MLB2", 5)}},
+			want:      []float64{10, 5},
+		},
+		{
+			name: "variations of the same item share its discount by gross",
+			items: []OrderItem{
+				{ItemID: "MLB1", VariationID: 1, Quantity: 1, UnitPrice: 100},
+				{ItemID: "MLB1", VariationID: 2, Quantity: 3, UnitPrice: 100},
+			},
+			discounts: &Discounts{Details: []DiscountDetail{discount("MLB1", 20)}},
+			want:      []float64{5, 15},
+		},
+		{
+			name: "variations without gross split evenly",
+			items: []OrderItem{
+				{ItemID: "MLB1", VariationID: 1, Quantity: 1},
+				{ItemID: "MLB1", VariationID: 2, Quantity: 1},
+			},
+			discounts: &Discounts{Details: []DiscountDetail{discount("MLB1", 8)}},
+			want:      []float64{4, 4},
+		},
+		{
+			name: "discounts in several details add up",
+			items: []OrderItem{
+				{ItemID: "MLB1", Quantity: 1, UnitPrice: 100},
+			},
+			discounts: &Discounts{Details: []DiscountDetail{discount("MLB1", 3), discount("MLB1", 2)}},
+			want:      []float64{5},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := Order{Items: tt.items, Discounts: tt.discounts}
+			got := order.SellerDiscountByLine()
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %v, want %v", got, tt.want)
+			}
+			var total float64
+			for i := range got {
+				total += got[i]
+				if math.Abs(got[i]-tt.want[i]) > 1e-9 {
+					t.Errorf("line %d: got %v, want %v", i, got[i], tt.want[i])
+				}
+			}
+			if math.Abs(total-order.sellerDiscount()) > 1e-9 {
+				t.Errorf("lines add up to %v, want %v", total, order.sellerDiscount())
+			}
+		})
+	}
+}
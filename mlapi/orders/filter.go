@@ -0,0 +1,88 @@
+package orders
+
+import (
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+)
+
+// APIDateLayout é o formato de data aceito nas buscas do Mercado Livre. O
+// offset é mantido, então a janela vale no fuso em que as datas foram criadas.
+const APIDateLayout = "2006-01-02T15:04:05.000-07:00"
+
+// DateField é o campo de data usado para recortar a busca de pedidos.
+type DateField string
+
+const (
+	DateCreated     DateField = "date_created"
+	DateClosed      DateField = "date_closed"
+	DateLastUpdated DateField = "date_last_updated"
+)
+
+// Filter define quais pedidos o FetchAll busca. Campos vazios não filtram.
+// SKU é aplicado localmente, pois o orders/search não filtra por seller_sku.
+type Filter struct {
+	Statuses  []string
+	Tags      []string
+	DateField DateField
+	ItemID    string
+	SKU       string
+}
+
+// DefaultFilter busca pedidos pagos ou confirmados pela data de criação,
+// excluindo os cancelados.
+func DefaultFilter() Filter {
+	return Filter{
+		Statuses:  []string{"paid", "confirmed"},
+		DateField: DateCreated,
+	}
+}
+
+func (f Filter) dateField() DateField {
+	if f.DateField == "" {
+		return DateCreated
+	}
+	return f.DateField
+}
+
+// query monta os parâmetros de busca do filtro para a janela informada.
+func (f Filter) query(dateFrom, dateTo time.Time) url.Values {
+	params := url.Values{}
+
+	field := "order." + string(f.dateField())
+	params.Set(field+".from", dateFrom.Format(APIDateLayout))
+	params.Set(field+".to", dateTo.Format(APIDateLayout))
+
+	if len(f.Statuses) > 0 {
+		params.Set("order.status", strings.Join(f.Statuses, ","))
+	}
+	if len(f.Tags) > 0 {
+		params.Set("tags", strings.Join(f.Tags, ","))
+	}
+	if f.ItemID != "" {
+		params.Set("item", f.ItemID)
+	}
+
+	return params
+}
+
+// Match informa se o pedido atende ao filtro, sem considerar as datas. É usado
+// para filtrar localmente pedidos que já estão em disco.
+func (f Filter) Match(order Order) bool {
+	if len(f.Statuses) > 0 && !slices.Contains(f.Statuses, order.Status) {
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !order.HasTag(tag) {
+			return false
+		}
+	}
+	if f.ItemID != "" && !slices.ContainsFunc(order.Items, func(i OrderItem) bool { return i.ItemID == f.ItemID }) {
+		return false
+	}
+	if f.SKU != "" && !slices.ContainsFunc(order.Items, func(i OrderItem) bool { return i.SKU == f.SKU }) {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,150 @@
+package orders
+
+import (
+	"encoding/json"
+	"time"
+
+	"dimi/kkalcs/timezone"
+)
+
+type OrderItem struct {
+	ItemID        string  `json:"item_id"`
+	Title         string  `json:"title"`
+	CategoryID    string  `json:"category_id"`
+	VariationID   int64   `json:"variation_id,omitempty"`
+	Quantity      int     `json:"quantity"`
+	UnitPrice     float64 `json:"unit_price"`
+	FullUnitPrice float64 `json:"full_unit_price"`
+	CurrencyID    string  `json:"currency_id"`
+	ListingTypeID string  `json:"listing_type_id"`
+	// SaleFee é a tarifa de venda por unidade, como o Mercado Livre informa em
+	// order_items.sale_fee. O total da linha é Fee.
+	SaleFee float64 `json:"sale_fee"`
+	SKU     string  `json:"seller_sku"`
+}
+
+type Buyer struct {
+	ID       int64  `json:"id"`
+	Nickname string `json:"nickname"`
+}
+
+// Payment é o resumo do pagamento que vem junto do pedido. Para o valor líquido
+// recebido é preciso consultar o pagamento no Mercado Pago.
+type Payment struct {
+	ID                        int64   `json:"id"`
+	OrderID                   int64   `json:"order_id"`
+	PayerID                   int64   `json:"payer_id"`
+	Status                    string  `json:"status"`
+	StatusDetail              string  `json:"status_detail"`
+	OperationType             string  `json:"operation_type"`
+	PaymentMethodID           string  `json:"payment_method_id"`
+	PaymentType               string  `json:"payment_type"`
+	Installments              int     `json:"installments"`
+	CurrencyID                string  `json:"currency_id"`
+	TransactionAmount         float64 `json:"transaction_amount"`
+	TransactionAmountRefunded float64 `json:"transaction_amount_refunded"`
+	TotalPaidAmount           float64 `json:"total_paid_amount"`
+	ShippingCost              float64 `json:"shipping_cost"`
+	CouponAmount              float64 `json:"coupon_amount"`
+	CouponID                  *string `json:"coupon_id"`
+	MarketplaceFee            float64 `json:"marketplace_fee"`
+	TaxesAmount               float64 `json:"taxes_amount"`
+	DateCreated               string  `json:"date_created"`
+	DateApproved              string  `json:"date_approved"`
+	DateLastModified          string  `json:"date_last_modified"`
+}
+
+type Taxes struct {
+	ID         *string  `json:"id"`
+	Amount     *float64 `json:"amount"`
+	CurrencyID *string  `json:"currency_id"`
+}
+
+type Coupon struct {
+	ID     *string `json:"id"`
+	Amount float64 `json:"amount"`
+}
+
+type Context struct {
+	Channel string   `json:"channel"`
+	Site    string   `json:"site"`
+	Flows   []string `json:"flows"`
+}
+
+type CancelDetail struct {
+	Group       string `json:"group"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	RequestedBy string `json:"requested_by"`
+	Date        string `json:"date"`
+}
+
+type Order struct {
+	OrderID      int64           `json:"order_id"`
+	PackID       int64           `json:"pack_id,omitempty"`
+	PaidAmount   float64         `json:"paid_amount"`
+	TotalAmount  float64         `json:"total_amount"`
+	CurrencyID   string          `json:"currency_id"`
+	Status       string          `json:"status"`
+	StatusDetail string          `json:"status_detail,omitempty"`
+	Tags         []string        `json:"tags"`
+	DateCreated  time.Time       `json:"date_created"`
+	DateClosed   string          `json:"date_closed"`
+	LastUpdated  string          `json:"last_updated"`
+	ShippingID   int             `json:"shipping_id"`
+	Buyer        Buyer           `json:"buyer"`
+	Payments     []Payment       `json:"payments"`
+	Taxes        Taxes           `json:"taxes"`
+	Coupon       Coupon          `json:"coupon"`
+	Context      Context         `json:"context"`
+	CancelDetail *CancelDetail   `json:"cancel_detail,omitempty"`
+	Discounts    *Discounts      `json:"discounts,omitempty"`
+	Items        []OrderItem     `json:"items"`
+	Raw          json.RawMessage `json:"raw,omitempty"`
+}
+
+// Gross é o valor bruto vendido do item.
+func (i OrderItem) Gross() float64 {
+	return i.UnitPrice * float64(i.Quantity)
+}
+
+// Fee é a tarifa de venda do item, somando todas as unidades.
+func (i OrderItem) Fee() float64 {
+	return i.SaleFee * float64(i.Quantity)
+}
+
+// Gross é o valor bruto vendido somando todos os itens do pedido.
+func (o Order) Gross() float64 {
+	var total float64
+	for _, item := range o.Items {
+		total += item.Gross()
+	}
+	return total
+}
+
+// Monthly agrupa pelo ano-mês da criação do pedido, no fuso configurado. Serve
+// como função de período para os relatórios que agrupam pedidos.
+func Monthly(order Order) string {
+	if order.DateCreated.IsZero() {
+		return ""
+	}
+	return timezone.In(order.DateCreated).Format("2006-01")
+}
+
+// Daily agrupa pelo dia da criação do pedido, no fuso configurado.
+func Daily(order Order) string {
+	if order.DateCreated.IsZero() {
+		return ""
+	}
+	return timezone.In(order.DateCreated).Format("2006-01-02")
+}
+
+// HasTag informa se o pedido possui a tag (ex.: "paid", "delivered", "pack_order").
+func (o Order) HasTag(tag string) bool {
+	for _, t := range o.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
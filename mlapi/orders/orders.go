@@ -3,121 +3,193 @@ package orders
 import (
 	"encoding/json"
 	"fmt"
-	"os"
-	"strings"
+	"log/slog"
+	"slices"
+	"strconv"
 	"time"
 
+	"dimi/kkalcs/mlapi"
 	"dimi/kkalcs/mlapi/requests"
 )
 
-type OrderItem struct {
-	ItemID        string  `json:"item_id"`
-	CategoryID    string  `json:"category_id"`
-	Quantity      int     `json:"quantity"`
-	UnitPrice     float64 `json:"unit_price"`
-	ListingTypeID string  `json:"listing_type_id"`
-	SaleFee       float64 `json:"sale_fee"`
-	SKU           string  `json:"seller_sku"`
-}
+// O orders/search não permite paginar além de maxOffset, então intervalos com mais
+// pedidos do que isso são divididos em janelas menores até caberem no limite.
+// maxPasses é quantas vezes uma janela por date_last_updated é lida quando a
+// quantidade de pedidos muda durante a paginação.
+const (
+	pageLimit = 50
+	maxOffset = 10000
+	minWindow = time.Minute
+	maxPasses = 3
+)
 
-type Order struct {
-	OrderID     int64       `json:"order_id"`
-	PaidAmount  float64     `json:"paid_amount"`
-	Status      string      `json:"status"`
-	DateCreated string      `json:"date_created"`
-	ShippingID  int         `json:"shipping_id"`
-	Items       []OrderItem `json:"items"`
-}
+func FetchAll(c *mlapi.Client, dateFrom, dateTo time.Time, filter Filter) ([]Order, error) {
+	sellerID, err := c.Seller()
+	if err != nil {
+		return nil, err
+	}
 
-func FetchAll(dateFrom, dateTo time.Time) ([]Order, error) {
-	const limit = 50
-	offset := 0
+	all_ords, err := fetchWindow(c, sellerID, dateFrom, dateTo, filter)
+	if err != nil {
+		return nil, err
+	}
 
-	dateFromString := dateFrom.Format("2006-01-02T15:04:05Z")
-	dateToString := dateTo.Format("2006-01-02T15:04:05Z")
+	if filter.SKU != "" {
+		all_ords = slices.DeleteFunc(all_ords, func(o Order) bool { return !filter.Match(o) })
+	}
 
-	// Status válidos para excluir pedidos cancelados
-	statuses := []string{"paid", "confirmed"}
-	validStatuses := strings.Join(statuses, ",")
+	return all_ords, nil
+}
 
-	all_ords := []Order{}
+// fetchWindow busca todos os pedidos entre dateFrom e dateTo (inclusive). Se o total
+// da janela passar do limite de offset, ela é dividida ao meio recursivamente.
+func fetchWindow(c *mlapi.Client, sellerID string, dateFrom, dateTo time.Time, filter Filter) ([]Order, error) {
+	ords, total, err := fetchPage(c, sellerID, dateFrom, dateTo, filter, 0)
+	if err != nil {
+		return nil, err
+	}
 
-	for {
-		url := fmt.Sprintf(
-			"https://api.mercadolibre.com/orders/search?seller=%s&limit=%d&offset=%d&order.date_created.from=%s&order.date_created.to=%s&order.status=%s",
-			requests.USER_ID, limit, offset, dateFromString, dateToString, validStatuses,
-		)
+	if total > maxOffset {
+		if dateTo.Sub(dateFrom) <= minWindow {
+			return nil, fmt.Errorf("janela de %s a %s possui %d pedidos, acima do limite de %d", dateFrom, dateTo, total, maxOffset)
+		}
 
-		body, err := requests.MakeSimpleRequest(requests.GET, url, nil)
+		// As datas da busca têm precisão de milissegundos, então a janela da
+		// direita começa 1 ms depois do meio para não perder nem repetir pedidos.
+		mid := dateFrom.Add(dateTo.Sub(dateFrom) / 2).Truncate(time.Millisecond)
+		slog.Debug("Dividindo janela de pedidos", "from", dateFrom, "mid", mid, "to", dateTo, "total", total)
 
+		left, err := fetchWindow(c, sellerID, dateFrom, mid, filter)
 		if err != nil {
-			return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+			return nil, err
 		}
-
-		ords, err := extract(body)
+		right, err := fetchWindow(c, sellerID, mid.Add(time.Millisecond), dateTo, filter)
 		if err != nil {
-			return nil, fmt.Errorf("erro ao extrair pedidos: %s", err)
+			return nil, err
 		}
+		return append(left, right...), nil
+	}
 
-		all_ords = append(all_ords, ords...)
+	all_ords, err := fetchPages(c, sellerID, dateFrom, dateTo, filter, ords, total)
+	if err != nil {
+		return nil, err
+	}
+	if len(all_ords) == total {
+		return all_ords, nil
+	}
 
-		var paging struct {
-			Total int `json:"total"`
+	// Pedidos alterados durante a paginação mudam de posição ou saem da janela
+	// de date_last_updated, então as páginas podem repetir ou pular pedidos.
+	if filter.dateField() == DateLastUpdated {
+		return refetchWindow(c, sellerID, dateFrom, dateTo, filter, all_ords)
+	}
+	return nil, fmt.Errorf("quantidade de pedidos divergente entre %s e %s: esperado %d, obtido %d", dateFrom, dateTo, total, len(all_ords))
+}
+
+// fetchPages busca as páginas seguintes à primeira (first) e retorna os pedidos
+// sem repetições.
+func fetchPages(c *mlapi.Client, sellerID string, dateFrom, dateTo time.Time, filter Filter, first []Order, total int) ([]Order, error) {
+	seen := make(map[int64]bool, total)
+	var all_ords []Order
+	add := func(ords []Order) {
+		for _, order := range ords {
+			if !seen[order.OrderID] {
+				seen[order.OrderID] = true
+				all_ords = append(all_ords, order)
+			}
 		}
-		err = json.Unmarshal(body, &paging)
+	}
+
+	add(first)
+	for offset := pageLimit; offset < total; offset += pageLimit {
+		slog.Debug("Buscando página de pedidos", "offset", offset, "total", total)
+
+		ords, _, err := fetchPage(c, sellerID, dateFrom, dateTo, filter, offset)
 		if err != nil {
-			return nil, fmt.Errorf("erro ao parsear a resposta de paginação: %s", err)
+			return nil, err
 		}
-
-		// Se a quantidade de pedidos retornados for menor que o limite, significa que não há mais páginas
-		if len(ords) < limit {
+		if len(ords) == 0 {
 			break
 		}
+		add(ords)
+	}
+	return all_ords, nil
+}
 
-		// Incrementa o offset para pegar a próxima página
-		offset += limit
-		fmt.Println(offset)
+// refetchWindow relê a janela até maxPasses vezes, juntando os pedidos pelo ID,
+// até uma leitura trazer todos os pedidos do total. Se a quantidade continuar
+// mudando, fica com o que juntou: os pedidos alterados voltam no próximo sync.
+func refetchWindow(c *mlapi.Client, sellerID string, dateFrom, dateTo time.Time, filter Filter, partial []Order) ([]Order, error) {
+	merged := partial
+	seen := make(map[int64]bool, len(partial))
+	for _, order := range partial {
+		seen[order.OrderID] = true
 	}
 
-	f, err := os.Create("all_orderns.json")
+	for pass := 2; pass <= maxPasses; pass++ {
+		slog.Debug("Relendo janela de pedidos alterados", "from", dateFrom, "to", dateTo, "pass", pass)
+
+		first, total, err := fetchPage(c, sellerID, dateFrom, dateTo, filter, 0)
+		if err != nil {
+			return nil, err
+		}
+		if total > maxOffset {
+			return nil, fmt.Errorf("janela de %s a %s possui %d pedidos, acima do limite de %d", dateFrom, dateTo, total, maxOffset)
+		}
+		ords, err := fetchPages(c, sellerID, dateFrom, dateTo, filter, first, total)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, order := range ords {
+			if !seen[order.OrderID] {
+				seen[order.OrderID] = true
+				merged = append(merged, order)
+			}
+		}
+		if len(ords) == total {
+			return merged, nil
+		}
+	}
+
+	slog.Warn("Quantidade de pedidos mudou durante a sincronização", "from", dateFrom, "to", dateTo, "fetched", len(merged))
+	return merged, nil
+}
+
+func fetchPage(c *mlapi.Client, sellerID string, dateFrom, dateTo time.Time, filter Filter, offset int) ([]Order, int, error) {
+	params := filter.query(dateFrom, dateTo)
+	params.Set("seller", sellerID)
+	params.Set("limit", strconv.Itoa(pageLimit))
+	params.Set("offset", strconv.Itoa(offset))
+
+	url := "https://api.mercadolibre.com/orders/search?" + params.Encode()
+
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
 	if err != nil {
-		return nil, err
+		return nil, 0, fmt.Errorf("erro ao fazer requisição: %s", err)
 	}
-	defer f.Close()
 
-	as_json, err := json.MarshalIndent(all_ords, "", "\t")
+	ords, err := extract(body)
 	if err != nil {
-		return nil, err
+		return nil, 0, fmt.Errorf("erro ao extrair pedidos: %s", err)
 	}
 
-	f.Write(as_json)
+	var paging struct {
+		Paging struct {
+			Total int `json:"total"`
+		} `json:"paging"`
+	}
+	err = json.Unmarshal(body, &paging)
+	if err != nil {
+		return nil, 0, fmt.Errorf("erro ao parsear a resposta de paginação: %s", err)
+	}
 
-	return all_ords, nil
+	return ords, paging.Paging.Total, nil
 }
 
 func extract(data []byte) ([]Order, error) {
 	var raw struct {
-		Results []struct {
-			ID           int64    `json:"id"`
-			Status       string   `json:"status"`
-			DateCreated  string   `json:"date_created"`
-			ShippingCost *float64 `json:"shipping_cost"` // pode ser nulo
-			PaidAmount   float64  `json:"paid_amount"`
-			Shipping     struct {
-				ID int `json:"id"`
-			} `json:"shipping"`
-			OrderItems []struct {
-				Item struct {
-					ID            string `json:"id"`
-					CategoryID    string `json:"category_id"`
-					ListingTypeID string `json:"listing_type_id"`
-					SKU           string `json:"seller_sku"`
-				} `json:"item"`
-				Quantity  int     `json:"quantity"`
-				UnitPrice float64 `json:"unit_price"`
-				SaleFee   float64 `json:"sale_fee"`
-			} `json:"order_items"`
-		} `json:"results"`
+		Results []json.RawMessage `json:"results"`
 	}
 
 	err := json.Unmarshal(data, &raw)
@@ -127,97 +199,142 @@ func extract(data []byte) ([]Order, error) {
 
 	var orders []Order
 	for _, r := range raw.Results {
-		order := Order{
-			OrderID:     r.ID,
-			Status:      r.Status,
-			DateCreated: r.DateCreated,
-			ShippingID:  r.Shipping.ID,
-			PaidAmount:  r.PaidAmount,
-		}
-
-		for _, oi := range r.OrderItems {
-			item := OrderItem{
-				ItemID:        oi.Item.ID,
-				CategoryID:    oi.Item.CategoryID,
-				Quantity:      oi.Quantity,
-				UnitPrice:     oi.UnitPrice,
-				ListingTypeID: oi.Item.ListingTypeID,
-				SaleFee:       oi.SaleFee,
-				SKU:           oi.Item.SKU,
-			}
-			order.Items = append(order.Items, item)
+		order, err := extractOrder(r)
+		if err != nil {
+			return nil, err
 		}
-
-		orders = append(orders, order)
+		orders = append(orders, *order)
 	}
 
 	return orders, nil
 }
 
-func Total(orders []Order) any {
-	var total float64
-	var sale_fee_total float64
-	for _, order := range orders {
-		for _, item := range order.Items {
-			total += item.UnitPrice * float64(item.Quantity)
-			sale_fee_total += item.SaleFee
-		}
+// extractOrder converte um pedido no formato da API para Order, guardando o JSON
+// original em Raw.
+func extractOrder(data json.RawMessage) (*Order, error) {
+	var r struct {
+		ID           int64         `json:"id"`
+		Status       string        `json:"status"`
+		StatusDetail *string       `json:"status_detail"`
+		DateCreated  time.Time     `json:"date_created"`
+		DateClosed   string        `json:"date_closed"`
+		LastUpdated  string        `json:"last_updated"`
+		PackID       *int64        `json:"pack_id"`
+		Tags         []string      `json:"tags"`
+		TotalAmount  float64       `json:"total_amount"`
+		PaidAmount   float64       `json:"paid_amount"`
+		CurrencyID   string        `json:"currency_id"`
+		Buyer        Buyer         `json:"buyer"`
+		Payments     []Payment     `json:"payments"`
+		Taxes        Taxes         `json:"taxes"`
+		Coupon       Coupon        `json:"coupon"`
+		Context      Context       `json:"context"`
+		CancelDetail *CancelDetail `json:"cancel_detail"`
+		Shipping     struct {
+			ID int `json:"id"`
+		} `json:"shipping"`
+		OrderItems []struct {
+			Item struct {
+				ID            string `json:"id"`
+				Title         string `json:"title"`
+				CategoryID    string `json:"category_id"`
+				VariationID   int64  `json:"variation_id"`
+				ListingTypeID string `json:"listing_type_id"`
+				SKU           string `json:"seller_sku"`
+			} `json:"item"`
+			Quantity      int     `json:"quantity"`
+			UnitPrice     float64 `json:"unit_price"`
+			FullUnitPrice float64 `json:"full_unit_price"`
+			CurrencyID    string  `json:"currency_id"`
+			SaleFee       float64 `json:"sale_fee"`
+		} `json:"order_items"`
 	}
-	median_tax := sale_fee_total / total
-	total_liquido := total - sale_fee_total
 
-	return struct { // This is an anonymous struct
-		TotalBruto   float64
-		SaleFeeTotal float64
-		MedianTax    float64
-		TotalLiquido float64
-	}{
-		TotalBruto:   total,
-		SaleFeeTotal: sale_fee_total,
-		MedianTax:    median_tax,
-		TotalLiquido: total_liquido,
+	err := json.Unmarshal(data, &r)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer unmarshal do pedido: %v", err)
 	}
-}
 
-func Get(orderId string) {
-	url := fmt.Sprintf("https://api.mercadolibre.com/orders/%s", orderId)
+	order := &Order{
+		OrderID:      r.ID,
+		Status:       r.Status,
+		DateCreated:  r.DateCreated,
+		DateClosed:   r.DateClosed,
+		LastUpdated:  r.LastUpdated,
+		ShippingID:   r.Shipping.ID,
+		PaidAmount:   r.PaidAmount,
+		TotalAmount:  r.TotalAmount,
+		CurrencyID:   r.CurrencyID,
+		Tags:         r.Tags,
+		Buyer:        r.Buyer,
+		Payments:     r.Payments,
+		Taxes:        r.Taxes,
+		Coupon:       r.Coupon,
+		Context:      r.Context,
+		CancelDetail: r.CancelDetail,
+		Raw:          data,
+	}
+	if r.StatusDetail != nil {
+		order.StatusDetail = *r.StatusDetail
+	}
+	if r.PackID != nil {
+		order.PackID = *r.PackID
+	}
 
-	body, err := requests.MakeSimpleRequest(requests.GET, url, nil)
-	if err != nil {
-		return
+	for _, oi := range r.OrderItems {
+		item := OrderItem{
+			ItemID:        oi.Item.ID,
+			Title:         oi.Item.Title,
+			CategoryID:    oi.Item.CategoryID,
+			VariationID:   oi.Item.VariationID,
+			Quantity:      oi.Quantity,
+			UnitPrice:     oi.UnitPrice,
+			FullUnitPrice: oi.FullUnitPrice,
+			CurrencyID:    oi.CurrencyID,
+			ListingTypeID: oi.Item.ListingTypeID,
+			SaleFee:       oi.SaleFee,
+			SKU:           oi.Item.SKU,
+		}
+		order.Items = append(order.Items, item)
 	}
 
-	fmt.Println("BODY:", string(body))
+	return order, nil
 }
 
-func Fetch() ([]Order, error) {
-	//url := fmt.Sprintf("https://api.mercadolibre.com/items?ids=%s&attributes=id,title,price,base_price,original_price", temp)
-	//url := fmt.Sprintf("https://api.mercadolibre.com/items/%s/prices", itemsId[0])
-	url := fmt.Sprintf("https://api.mercadolibre.com/orders/search?seller=%s", requests.USER_ID)
-	fmt.Println("URL:", url)
-
-	body, err := requests.MakeSimpleRequest(requests.GET, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+// RevenueBySKU soma o valor bruto vendido de cada SKU.
+func RevenueBySKU(orders []Order) map[string]float64 {
+	bySKU := make(map[string]float64)
+	for _, order := range orders {
+		for _, item := range order.Items {
+			bySKU[item.SKU] += item.UnitPrice * float64(item.Quantity)
+		}
 	}
+	return bySKU
+}
 
-	ords, err := extract(body)
-	if err != nil {
-		return nil, fmt.Errorf("erro ao extrair pedidos: %s", err)
+// ItemIDs retorna os IDs de anúncio distintos dos pedidos, na ordem em que aparecem.
+func ItemIDs(orders []Order) []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, order := range orders {
+		for _, item := range order.Items {
+			if item.ItemID == "" || seen[item.ItemID] {
+				continue
+			}
+			seen[item.ItemID] = true
+			ids = append(ids, item.ItemID)
+		}
 	}
+	return ids
+}
 
-	f, err := os.Create("fetch_orders.json")
+func Get(c *mlapi.Client, orderId string) (*Order, error) {
+	url := fmt.Sprintf("https://api.mercadolibre.com/orders/%s", orderId)
+
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
 	}
-	defer f.Close()
-
-	as_json, _ := json.MarshalIndent(ords, "", "\t")
-	f.Write(as_json)
-
-	Total(ords)
-
-	fmt.Println("pedidos:", ords)
 
-	return ords, nil
+	return extractOrder(body)
 }
@@ -0,0 +1,243 @@
+package orders
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"dimi/kkalcs/mlapi"
+)
+
+type staticToken string
+
+func (t staticToken) AccessToken() (string, error) { return string(t), nil }
+
+// fakeSearch responde ao orders/search com os pedidos cuja data de criação
+// está na janela pedida, paginados como a API e recusando offsets acima de
+// maxOffset.
+type fakeSearch struct {
+	dates []time.Time
+
+	mu      sync.Mutex
+	windows map[string]bool
+}
+
+func (f *fakeSearch) RoundTrip(req *http.Request) (*http.Response, error) {
+	query := req.URL.Query()
+	from, err := time.Parse(APIDateLayout, query.Get("order.date_created.from"))
+	if err != nil {
+		return nil, err
+	}
+	to, err := time.Parse(APIDateLayout, query.Get("order.date_created.to"))
+	if err != nil {
+		return nil, err
+	}
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	f.mu.Lock()
+	f.windows[query.Get("order.date_created.from")+".."+query.Get("order.date_created.to")] = true
+	f.mu.Unlock()
+
+	if offset > maxOffset {
+		return &http.Response{StatusCode: http.StatusBadRequest, Body: io.NopCloser(bytes.NewReader(nil))}, nil
+	}
+
+	var results []map[string]any
+	total := 0
+	for i, d := range f.dates {
+		if d.Before(from) || d.After(to) {
+			continue
+		}
+		if total >= offset && total < offset+limit {
+			results = append(results, map[string]any{
+				"id":           i + 1,
+				"status":       "paid",
+				"date_created": d.Format(APIDateLayout),
+			})
+		}
+		total++
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"results": results,
+		"paging":  map[string]int{"total": total, "offset": offset, "limit": limit},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func TestFetchAllSplitsWindows(t *testing.T) {
+	from := time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := from.Add(24*time.Hour - time.Millisecond)
+
+	// spread distribui n pedidos pelo dia com passo step, em milissegundos
+	// quebrados para cair entre os segundos das janelas.
+	spread := func(n int, step time.Duration) []time.Time {
+		dates := make([]time.Time, n)
+		for i := range dates {
+			dates[i] = from.Add(time.Duration(i) * step)
+		}
+		return dates
+	}
+
+	tests := []struct {
+		name      string
+		dates     []time.Time
+		wantSplit bool
+		wantErr   bool
+	}{
+		{name: "empty window", dates: nil},
+		{name: "single page", dates: spread(30, time.Minute+123*time.Millisecond)},
+		{name: "several pages", dates: spread(175, 7*time.Minute+321*time.Millisecond)},
+		{name: "exactly the offset limit", dates: spread(maxOffset, 8*time.Second+639*time.Millisecond)},
+		{name: "above the offset limit", dates: spread(25000, 3*time.Second+455*time.Millisecond), wantSplit: true},
+		{
+			// O meio da janela é 11:59:59.999; o pedido cai entre os segundos.
+			name:      "order between seconds at the split",
+			dates:     append(spread(25000, 3*time.Second+455*time.Millisecond), from.Add(12*time.Hour-500*time.Millisecond)),
+			wantSplit: true,
+		},
+		{name: "too many orders in the smallest window", dates: spread(maxOffset+1, time.Millisecond), wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeSearch{dates: tt.dates, windows: make(map[string]bool)}
+			c := mlapi.NewClient("123", staticToken("token"))
+			c.HTTP = &http.Client{Transport: fake}
+
+			ords, err := FetchAll(c, from, to, DefaultFilter())
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %d orders", len(ords))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(ords) != len(tt.dates) {
+				t.Errorf("got %d orders, want %d", len(ords), len(tt.dates))
+			}
+			seen := make(map[int64]bool, len(ords))
+			for _, o := range ords {
+				if seen[o.OrderID] {
+					t.Fatalf("order %d returned twice", o.OrderID)
+				}
+				seen[o.OrderID] = true
+			}
+			if split := len(fake.windows) > 1; split != tt.wantSplit {
+				t.Errorf("split = %v, want %v (%d windows)", split, tt.wantSplit, len(fake.windows))
+			}
+		})
+	}
+}
+
+// driftSearch responde ao orders/search por date_last_updated com os pedidos de
+// ids, paginados como a API. Na segunda requisição, move altera a lista, como
+// acontece quando um pedido é atualizado no meio da paginação.
+type driftSearch struct {
+	ids  []int64
+	move func(ids []int64) []int64
+
+	mu       sync.Mutex
+	requests int
+}
+
+func (f *driftSearch) RoundTrip(req *http.Request) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests++
+	if f.requests == 2 {
+		f.ids = f.move(f.ids)
+	}
+
+	query := req.URL.Query()
+	offset, _ := strconv.Atoi(query.Get("offset"))
+	limit, _ := strconv.Atoi(query.Get("limit"))
+
+	var results []map[string]any
+	for i := offset; i < offset+limit && i < len(f.ids); i++ {
+		results = append(results, map[string]any{"id": f.ids[i], "status": "paid"})
+	}
+
+	body, err := json.Marshal(map[string]any{
+		"results": results,
+		"paging":  map[string]int{"total": len(f.ids), "offset": offset, "limit": limit},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+type memStore struct {
+	orders []Order
+}
+
+func (s *memStore) LastSync() (time.Time, error) { return time.Time{}, nil }
+
+func (s *memStore) Merge(ords []Order, syncedAt time.Time) error {
+	s.orders = ords
+	return nil
+}
+
+func TestSyncToleratesDrift(t *testing.T) {
+	ids := make([]int64, 60)
+	for i := range ids {
+		ids[i] = int64(i + 1)
+	}
+
+	tests := []struct {
+		name string
+		move func(ids []int64) []int64
+		want int
+	}{
+		{
+			// O primeiro pedido foi atualizado depois do fim da janela e saiu dela.
+			name: "order leaves the window",
+			move: func(ids []int64) []int64 { return ids[1:] },
+			want: 60,
+		},
+		{
+			// O primeiro pedido foi atualizado e passou para a última página.
+			name: "order moves to a later page",
+			move: func(ids []int64) []int64 { return append(ids[1:], ids[0]) },
+			want: 60,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &driftSearch{ids: slices.Clone(ids), move: tt.move}
+			c := mlapi.NewClient("123", staticToken("token"))
+			c.HTTP = &http.Client{Transport: fake}
+
+			store := &memStore{}
+			result, err := Sync(c, store, time.Date(2025, time.March, 1, 0, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if result.Fetched != tt.want {
+				t.Errorf("got %d orders, want %d", result.Fetched, tt.want)
+			}
+			seen := make(map[int64]bool, len(store.orders))
+			for _, o := range store.orders {
+				if seen[o.OrderID] {
+					t.Fatalf("order %d returned twice", o.OrderID)
+				}
+				seen[o.OrderID] = true
+			}
+		})
+	}
+}
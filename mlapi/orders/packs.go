@@ -0,0 +1,116 @@
+package orders
+
+import (
+	"sort"
+	"time"
+)
+
+// Pack agrupa os pedidos de um mesmo carrinho. O Mercado Livre divide o carrinho
+// em vários pedidos com o mesmo pack_id, mas todos compartilham um único envio.
+// Pedidos sem pack_id formam um pack sozinhos, com ID igual ao do pedido.
+type Pack struct {
+	ID         int64   `json:"pack_id"`
+	Orders     []Order `json:"orders"`
+	ShippingID int     `json:"shipping_id"`
+}
+
+// ItemAllocation é a parte de um item do pack nos custos que são cobrados por pack.
+type ItemAllocation struct {
+	OrderID  int64   `json:"order_id"`
+	ItemID   string  `json:"item_id"`
+	SKU      string  `json:"seller_sku"`
+	Quantity int     `json:"quantity"`
+	Gross    float64 `json:"gross"`
+	SaleFee  float64 `json:"sale_fee"`
+	Shipping float64 `json:"shipping"`
+}
+
+// GroupPacks agrupa os pedidos por pack_id, mantendo a ordem em que cada pack
+// aparece pela primeira vez.
+func GroupPacks(orders []Order) []Pack {
+	index := make(map[int64]int)
+	var packs []Pack
+
+	for _, order := range orders {
+		id := order.PackID
+		if id == 0 {
+			id = order.OrderID
+		}
+
+		i, ok := index[id]
+		if !ok {
+			i = len(packs)
+			index[id] = i
+			packs = append(packs, Pack{ID: id})
+		}
+
+		p := &packs[i]
+		p.Orders = append(p.Orders, order)
+		if p.ShippingID == 0 {
+			p.ShippingID = order.ShippingID
+		}
+	}
+
+	for i := range packs {
+		sort.Slice(packs[i].Orders, func(a, b int) bool {
+			return packs[i].Orders[a].OrderID < packs[i].Orders[b].OrderID
+		})
+	}
+
+	return packs
+}
+
+// SoldAt é a criação do pedido mais antigo do pack.
+func (p Pack) SoldAt() time.Time {
+	var at time.Time
+	for _, order := range p.Orders {
+		if at.IsZero() || order.DateCreated.Before(at) {
+			at = order.DateCreated
+		}
+	}
+	return at
+}
+
+// Gross é o valor bruto dos itens de todos os pedidos do pack.
+func (p Pack) Gross() float64 {
+	var total float64
+	for _, order := range p.Orders {
+		for _, item := range order.Items {
+			total += item.UnitPrice * float64(item.Quantity)
+		}
+	}
+	return total
+}
+
+// Allocate divide o custo de envio do pack entre os itens, proporcionalmente ao
+// valor bruto de cada um. Se o pack não tiver valor, divide pela quantidade.
+// A tarifa de venda já vem por item e é apenas repassada.
+func (p Pack) Allocate(shippingCost float64) []ItemAllocation {
+	var allocs []ItemAllocation
+	var units int
+	for _, order := range p.Orders {
+		for _, item := range order.Items {
+			allocs = append(allocs, ItemAllocation{
+				OrderID:  order.OrderID,
+				ItemID:   item.ItemID,
+				SKU:      item.SKU,
+				Quantity: item.Quantity,
+				Gross:    item.UnitPrice * float64(item.Quantity),
+				SaleFee:  item.Fee(),
+			})
+			units += item.Quantity
+		}
+	}
+
+	gross := p.Gross()
+	for i := range allocs {
+		switch {
+		case gross > 0:
+			allocs[i].Shipping = shippingCost * allocs[i].Gross / gross
+		case units > 0:
+			allocs[i].Shipping = shippingCost * float64(allocs[i].Quantity) / float64(units)
+		}
+	}
+
+	return allocs
+}
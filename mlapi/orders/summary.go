@@ -0,0 +1,135 @@
+package orders
+
+// Nomes dos tipos de anúncio usados nas quebras do resumo.
+var listingTypeNames = map[string]string{
+	"gold_special": "classic",
+	"gold_pro":     "premium",
+	"free":         "free",
+}
+
+// ListingTypeName traduz o listing_type_id para classic/premium. IDs
+// desconhecidos são retornados como vieram.
+func ListingTypeName(id string) string {
+	if name, ok := listingTypeNames[id]; ok {
+		return name
+	}
+	return id
+}
+
+// Breakdown são os totais de um recorte do resumo. Um pedido com itens em mais
+// de um recorte conta como pedido em cada um deles.
+type Breakdown struct {
+	// Orders é a quantidade de pedidos com pelo menos um item no recorte.
+	Orders int `json:"orders"`
+	// Units é a soma das quantidades vendidas.
+	Units int `json:"units"`
+	// Gross é o valor bruto vendido (preço unitário × quantidade).
+	Gross float64 `json:"gross"`
+	// SaleFee é a soma das tarifas de venda.
+	SaleFee float64 `json:"sale_fee"`
+	// SellerDiscount são os descontos bancados pelo vendedor.
+	SellerDiscount float64 `json:"seller_discount"`
+	// Net é Gross - SaleFee - SellerDiscount.
+	Net float64 `json:"net"`
+	// FeeRate é SaleFee / Gross, ou 0 sem vendas.
+	FeeRate float64 `json:"fee_rate"`
+	// AverageTicket é Gross / Orders, ou 0 sem pedidos.
+	AverageTicket float64 `json:"average_ticket"`
+}
+
+func (b *Breakdown) finish() {
+	b.Net = b.Gross - b.SaleFee - b.SellerDiscount
+	if b.Gross > 0 {
+		b.FeeRate = b.SaleFee / b.Gross
+	}
+	if b.Orders > 0 {
+		b.AverageTicket = b.Gross / float64(b.Orders)
+	}
+}
+
+// Summary é o resumo de vendas de um conjunto de pedidos, usado também como
+// resposta JSON da api.
+type Summary struct {
+	Breakdown
+	// ByListingType separa por tipo de anúncio (classic, premium, ...).
+	ByListingType map[string]Breakdown `json:"by_listing_type"`
+	ByCategory    map[string]Breakdown `json:"by_category"`
+	BySKU         map[string]Breakdown `json:"by_sku"`
+	// ByDay usa a data de criação do pedido, no formato AAAA-MM-DD.
+	ByDay map[string]Breakdown `json:"by_day"`
+}
+
+// Total calcula o resumo dos pedidos. Descontos bancados pelo vendedor só
+// entram se os pedidos passaram por AttachDiscounts.
+func Total(orders []Order) Summary {
+	summary := Summary{
+		ByListingType: make(map[string]Breakdown),
+		ByCategory:    make(map[string]Breakdown),
+		BySKU:         make(map[string]Breakdown),
+		ByDay:         make(map[string]Breakdown),
+	}
+
+	for _, order := range orders {
+		discounts := order.SellerDiscountByLine()
+
+		day := Daily(order)
+
+		summary.Orders++
+		counted := map[string]map[string]bool{
+			"listing_type": {}, "category": {}, "sku": {}, "day": {},
+		}
+
+		for i, item := range order.Items {
+			line := Breakdown{
+				Units:          item.Quantity,
+				Gross:          item.Gross(),
+				SaleFee:        item.Fee(),
+				SellerDiscount: discounts[i],
+			}
+			summary.Breakdown.add(line)
+
+			addTo(summary.ByListingType, ListingTypeName(item.ListingTypeID), line, counted["listing_type"])
+			addTo(summary.ByCategory, item.CategoryID, line, counted["category"])
+			addTo(summary.BySKU, item.SKU, line, counted["sku"])
+			addTo(summary.ByDay, day, line, counted["day"])
+		}
+
+		// Descontos sem item correspondente ainda entram no total do pedido.
+		if order.Discounts != nil {
+			var byItem float64
+			for _, d := range discounts {
+				byItem += d
+			}
+			summary.SellerDiscount += order.sellerDiscount() - byItem
+		}
+	}
+
+	summary.finish()
+	for _, m := range []map[string]Breakdown{summary.ByListingType, summary.ByCategory, summary.BySKU, summary.ByDay} {
+		for k, b := range m {
+			b.finish()
+			m[k] = b
+		}
+	}
+
+	return summary
+}
+
+func (b *Breakdown) add(o Breakdown) {
+	b.Orders += o.Orders
+	b.Units += o.Units
+	b.Gross += o.Gross
+	b.SaleFee += o.SaleFee
+	b.SellerDiscount += o.SellerDiscount
+}
+
+// addTo soma a linha no recorte key, contando o pedido só uma vez por recorte.
+func addTo(m map[string]Breakdown, key string, line Breakdown, counted map[string]bool) {
+	b := m[key]
+	b.add(line)
+	if !counted[key] {
+		counted[key] = true
+		b.Orders++
+	}
+	m[key] = b
+}
@@ -0,0 +1,61 @@
+package orders
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"dimi/kkalcs/mlapi"
+)
+
+// syncOverlap recua a marca d'água em cada sync para não perder pedidos
+// atualizados enquanto a sincronização anterior rodava.
+const syncOverlap = 10 * time.Minute
+
+// SyncStore guarda os pedidos sincronizados e a marca d'água da última sincronização.
+type SyncStore interface {
+	LastSync() (time.Time, error)
+	Merge(orders []Order, syncedAt time.Time) error
+}
+
+type SyncResult struct {
+	From    time.Time `json:"from"`
+	To      time.Time `json:"to"`
+	Fetched int       `json:"fetched"`
+}
+
+// Sync busca apenas os pedidos alterados desde a última sincronização, usando
+// order.date_last_updated, e os mescla no store pelo ID. Na primeira execução,
+// sem marca d'água, busca a partir de initialFrom.
+func Sync(c *mlapi.Client, store SyncStore, initialFrom time.Time) (*SyncResult, error) {
+	sellerID, err := c.Seller()
+	if err != nil {
+		return nil, err
+	}
+
+	from, err := store.LastSync()
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler a última sincronização: %s", err)
+	}
+	if from.IsZero() {
+		from = initialFrom
+	} else {
+		from = from.Add(-syncOverlap)
+	}
+	to := time.Now().UTC().Truncate(time.Second)
+
+	filter := Filter{DateField: DateLastUpdated}
+	ords, err := fetchWindow(c, sellerID, from, to, filter)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao buscar pedidos alterados: %s", err)
+	}
+
+	err = store.Merge(ords, to)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao salvar pedidos: %s", err)
+	}
+
+	slog.Info("Pedidos sincronizados", "from", from, "to", to, "fetched", len(ords))
+
+	return &SyncResult{From: from, To: to, Fetched: len(ords)}, nil
+}
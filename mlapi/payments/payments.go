@@ -0,0 +1,189 @@
+package payments
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"dimi/kkalcs/fanout"
+	"dimi/kkalcs/mlapi"
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/requests"
+)
+
+// Tipos de tarifa em fee_details.
+const (
+	FeeMercadoLivre = "ml_fee"
+	FeeApplication  = "application_fee"
+	FeeMercadoPago  = "mercadopago_fee"
+	FeeFinancing    = "financing_fee"
+)
+
+type FeeDetail struct {
+	Type     string  `json:"type"`
+	Amount   float64 `json:"amount"`
+	FeePayer string  `json:"fee_payer"`
+}
+
+// Payment é o pagamento visto pelo Mercado Pago, com o que de fato cai na conta.
+type Payment struct {
+	ID                 int64       `json:"id"`
+	OrderID            int64       `json:"order_id"`
+	Status             string      `json:"status"`
+	StatusDetail       string      `json:"status_detail"`
+	CurrencyID         string      `json:"currency_id"`
+	Installments       int         `json:"installments"`
+	TransactionAmount  float64     `json:"transaction_amount"`
+	NetReceivedAmount  float64     `json:"net_received_amount"`
+	TotalPaidAmount    float64     `json:"total_paid_amount"`
+	FeeDetails         []FeeDetail `json:"fee_details"`
+	DateApproved       string      `json:"date_approved"`
+	MoneyReleaseDate   string      `json:"money_release_date"`
+	MoneyReleaseStatus string      `json:"money_release_status"`
+}
+
+// fees soma as tarifas dos tipos informados pagas pelo vendedor (collector).
+func (p Payment) fees(types ...string) float64 {
+	var total float64
+	for _, fee := range p.FeeDetails {
+		if fee.FeePayer != "" && fee.FeePayer != "collector" {
+			continue
+		}
+		for _, t := range types {
+			if fee.Type == t {
+				total += fee.Amount
+			}
+		}
+	}
+	return total
+}
+
+// MLFee é a tarifa de venda do Mercado Livre descontada do pagamento.
+func (p Payment) MLFee() float64 {
+	return p.fees(FeeMercadoLivre, FeeApplication)
+}
+
+// FinancingFee é o custo do parcelamento sem juros bancado pelo vendedor.
+func (p Payment) FinancingFee() float64 {
+	return p.fees(FeeFinancing)
+}
+
+// TotalFees soma todas as tarifas pagas pelo vendedor.
+func (p Payment) TotalFees() float64 {
+	return p.fees(FeeMercadoLivre, FeeApplication, FeeMercadoPago, FeeFinancing)
+}
+
+func Fetch(c *mlapi.Client, paymentID int64) (*Payment, error) {
+	url := fmt.Sprintf("https://api.mercadopago.com/v1/payments/%d", paymentID)
+
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+	}
+
+	var raw struct {
+		Payment
+		Order struct {
+			ID json.RawMessage `json:"id"`
+		} `json:"order"`
+		TransactionDetails struct {
+			NetReceivedAmount float64 `json:"net_received_amount"`
+			TotalPaidAmount   float64 `json:"total_paid_amount"`
+		} `json:"transaction_details"`
+	}
+	err = json.Unmarshal(body, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+	}
+
+	payment := raw.Payment
+	// O ID do pedido pode vir como número ou como string.
+	orderID, _ := strconv.ParseInt(strings.Trim(string(raw.Order.ID), `"`), 10, 64)
+	payment.OrderID = orderID
+	payment.NetReceivedAmount = raw.TransactionDetails.NetReceivedAmount
+	payment.TotalPaidAmount = raw.TransactionDetails.TotalPaidAmount
+
+	return &payment, nil
+}
+
+// FetchForOrders busca em paralelo os pagamentos de todos os pedidos e os agrupa
+// pelo ID do pedido.
+func FetchForOrders(c *mlapi.Client, ords []orders.Order) (map[int64][]Payment, fanout.Errors[int64]) {
+	orderOf := make(map[int64]int64)
+	var ids []int64
+	for _, order := range ords {
+		for _, p := range order.Payments {
+			orderOf[p.ID] = order.OrderID
+			ids = append(ids, p.ID)
+		}
+	}
+
+	opts := fanout.Options{Workers: 8, Interval: 50 * time.Millisecond}
+	found, errs := fanout.Run(ids, opts, func(id int64) (*Payment, error) {
+		return Fetch(c, id)
+	})
+
+	byOrder := make(map[int64][]Payment)
+	for id, p := range found {
+		if p.OrderID == 0 {
+			p.OrderID = orderOf[id]
+		}
+		byOrder[p.OrderID] = append(byOrder[p.OrderID], *p)
+	}
+
+	return byOrder, errs
+}
+
+// CashFlowDay é o que é liberado na conta em um dia.
+type CashFlowDay struct {
+	Date         string  `json:"date"`
+	Payments     int     `json:"payments"`
+	Gross        float64 `json:"gross"`
+	MLFee        float64 `json:"ml_fee"`
+	FinancingFee float64 `json:"financing_fee"`
+	Net          float64 `json:"net"`
+}
+
+// CashFlow agrupa os pagamentos aprovados pela data de liberação do dinheiro.
+func CashFlow(pays []Payment) []CashFlowDay {
+	byDate := make(map[string]*CashFlowDay)
+	for _, p := range pays {
+		if p.Status != "approved" || len(p.MoneyReleaseDate) < 10 {
+			continue
+		}
+		date := p.MoneyReleaseDate[:10]
+
+		day, ok := byDate[date]
+		if !ok {
+			day = &CashFlowDay{Date: date}
+			byDate[date] = day
+		}
+		day.Payments++
+		day.Gross += p.TransactionAmount
+		day.MLFee += p.MLFee()
+		day.FinancingFee += p.FinancingFee()
+		day.Net += p.NetReceivedAmount
+	}
+
+	days := make([]CashFlowDay, 0, len(byDate))
+	for _, day := range byDate {
+		days = append(days, *day)
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Date < days[j].Date })
+
+	return days
+}
+
+// NetReceived soma o valor líquido recebido dos pagamentos aprovados.
+func NetReceived(pays []Payment) float64 {
+	var total float64
+	for _, p := range pays {
+		if p.Status == "approved" {
+			total += p.NetReceivedAmount
+		}
+	}
+	return total
+}
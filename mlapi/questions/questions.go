@@ -0,0 +1,50 @@
+package questions
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"dimi/kkalcs/mlapi"
+	"dimi/kkalcs/mlapi/requests"
+)
+
+type Answer struct {
+	Text        string `json:"text"`
+	Status      string `json:"status"`
+	DateCreated string `json:"date_created"`
+}
+
+// Question é uma pergunta feita em um anúncio do vendedor. Answer é nil
+// enquanto ela não foi respondida.
+type Question struct {
+	ID          int64   `json:"id"`
+	ItemID      string  `json:"item_id"`
+	SellerID    int64   `json:"seller_id"`
+	Status      string  `json:"status"`
+	Text        string  `json:"text"`
+	DateCreated string  `json:"date_created"`
+	Answer      *Answer `json:"answer"`
+}
+
+// Answered informa se a pergunta já tem resposta.
+func (q Question) Answered() bool {
+	return q.Status == "ANSWERED"
+}
+
+// Get busca uma pergunta pelo ID.
+func Get(c *mlapi.Client, questionID string) (*Question, error) {
+	url := fmt.Sprintf("https://api.mercadolibre.com/questions/%s", questionID)
+
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+	}
+
+	var question Question
+	err = json.Unmarshal(body, &question)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao fazer unmarshal: %v", err)
+	}
+
+	return &question, nil
+}
@@ -2,19 +2,27 @@ package requests
 
 import (
 	"bytes"
-	"dimi/kkalcs/mlapi/auth"
 	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
-)
 
-var USER_ID string
+	"dimi/kkalcs/logger"
+)
 
 type Method string
 
+// StatusError é retornado quando a API responde com um status diferente de 200 e 201.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("error: status code %d", e.StatusCode)
+}
+
 const (
 	GET    Method = http.MethodGet
 	POST   Method = http.MethodPost
@@ -22,7 +30,9 @@ const (
 	DELETE Method = http.MethodDelete
 )
 
-func MakeRequest(method Method, url string, body *bytes.Buffer) (*http.Response, error) {
+// MakeRequest executa a requisição autenticada com o token informado. Respostas
+// diferentes de 200 e 201 são retornadas como erro.
+func MakeRequest(client *http.Client, accessToken string, method Method, url string, body *bytes.Buffer) (*http.Response, error) {
 	slog.Debug("Making request", "method", method, "url", url)
 	var bodyReader io.Reader
 	if body != nil {
@@ -31,37 +41,36 @@ func MakeRequest(method Method, url string, body *bytes.Buffer) (*http.Response,
 		bodyReader = nil
 	}
 
-	access_token := auth.GetAcessToken()
-	var bearer = "Bearer " + access_token
+	var bearer = "Bearer " + accessToken
 
 	req, err := http.NewRequest(string(method), url, bodyReader)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	req.Header.Add("Authorization", bearer)
 	req.Header.Add("Content-Type", "application/json")
 
-	client := &http.Client{}
 	resp, err := client.Do(req)
 
 	if err != nil {
-		return nil, err
+		return nil, logger.RedactError(err)
 	}
 	if resp.StatusCode != 200 && resp.StatusCode != 201 {
 		body, _ := io.ReadAll(resp.Body)
-		slog.Debug("Request failed ", "Response Body:", string(body))
-		return nil, fmt.Errorf("error: status code %d", resp.StatusCode)
+		resp.Body.Close()
+		slog.Debug("Request failed ", "Response Body:", logger.Redact(string(body)))
+		return nil, &StatusError{StatusCode: resp.StatusCode}
 	}
 
 	return resp, nil
 }
 
-func MakeSimpleRequest(method Method, url string, body *bytes.Buffer) ([]byte, error) {
+func MakeSimpleRequest(client *http.Client, accessToken string, method Method, url string, body *bytes.Buffer) ([]byte, error) {
 
-	resp, err := MakeRequest(method, url, body)
+	resp, err := MakeRequest(client, accessToken, method, url, body)
 	if err != nil {
-		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
+		return nil, fmt.Errorf("erro ao fazer requisição: %w", err)
 	}
 	bodybyte, err := io.ReadAll(resp.Body)
 	resp.Body.Close()
@@ -1,9 +1,11 @@
 package shipments
 
 import (
+	"dimi/kkalcs/mlapi"
 	"dimi/kkalcs/mlapi/requests"
 	"encoding/json"
 	"fmt"
+	"time"
 )
 
 type ShipmentCost struct {
@@ -11,13 +13,48 @@ type ShipmentCost struct {
 	Cost       float64 // Quanto o vendedor pagou de frete
 	ChargeFlex float64 // Taxa adicional se usada entrega Flex
 	Discount   float64
-	FinalCost  float64 // o valor realmente pago pelo vendedor
+	FinalCost  float64   // o valor realmente pago pelo vendedor
+	FetchedAt  time.Time // quando o custo foi buscado no Mercado Livre
 }
 
-func FetchCosts(shipmentID string) (*ShipmentCost, error) {
+const (
+	// CostsMaxAge é por quanto tempo um custo buscado vale antes de ser buscado
+	// de novo, enquanto ainda pode mudar.
+	CostsMaxAge = 24 * time.Hour
+	// CostsSettleAfter é o prazo depois da venda a partir do qual o custo não
+	// muda mais: um custo buscado depois disso nunca fica desatualizado.
+	CostsSettleAfter = 30 * 24 * time.Hour
+)
+
+// Stale informa se o custo precisa ser buscado de novo. soldAt é a data da
+// venda do envio. Custos sem FetchedAt, salvos antes dele existir, são
+// sempre desatualizados.
+func (s ShipmentCost) Stale(soldAt time.Time) bool {
+	if s.FetchedAt.IsZero() {
+		return true
+	}
+	if s.FetchedAt.After(soldAt.Add(CostsSettleAfter)) {
+		return false
+	}
+	return time.Since(s.FetchedAt) > CostsMaxAge
+}
+
+// Missing retorna os envios que precisam ser buscados: os que não estão em
+// cached e os desatualizados. soldAt é a data da venda de cada envio.
+func Missing(ids []string, cached map[string]ShipmentCost, soldAt map[string]time.Time) []string {
+	var missing []string
+	for _, id := range ids {
+		if cost, ok := cached[id]; !ok || cost.Stale(soldAt[id]) {
+			missing = append(missing, id)
+		}
+	}
+	return missing
+}
+
+func FetchCosts(c *mlapi.Client, shipmentID string) (*ShipmentCost, error) {
 	url := fmt.Sprintf("https://api.mercadolibre.com/shipments/%s/costs", shipmentID)
 
-	body, err := requests.MakeSimpleRequest(requests.GET, url, nil)
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
 	if err != nil {
 		return nil, fmt.Errorf("erro ao fazer requisição: %s", err)
 	}
@@ -32,16 +69,14 @@ func FetchCosts(shipmentID string) (*ShipmentCost, error) {
 	return shipmentCost, nil
 }
 
-func Fetch(shipmentID string) (int, error) {
+func Fetch(c *mlapi.Client, shipmentID string) (int, error) {
 	url := fmt.Sprintf("https://api.mercadolibre.com/shipments/%s", shipmentID)
 
-	body, err := requests.MakeSimpleRequest(requests.GET, url, nil)
+	body, err := c.MakeSimpleRequest(requests.GET, url, nil)
 	if err != nil {
 		return 0, fmt.Errorf("erro ao fazer requisição: %s", err)
 	}
 
-	fmt.Println("FECHING RESULT:", string(body))
-
 	var raw struct {
 		Order_id int `json:"order_id"`
 	}
@@ -98,6 +133,7 @@ func extract(data []byte, shipmentID string) (*ShipmentCost, error) {
 		ChargeFlex: s.Charges.ChargeFlex,
 		Discount:   totalDiscount,
 		FinalCost:  finalCost,
+		FetchedAt:  time.Now(),
 	}
 
 	return result, nil
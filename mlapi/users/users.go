@@ -0,0 +1,47 @@
+package users
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"dimi/kkalcs/mlapi"
+)
+
+type User struct {
+	ID        int64  `json:"id"`
+	Nickname  string `json:"nickname"`
+	CountryID string `json:"country_id"`
+	SiteID    string `json:"site_id"`
+	Permalink string `json:"permalink"`
+}
+
+// Result guarda o usuário de um ID ou o erro ao buscá-lo.
+type Result struct {
+	ID   string
+	User *User
+	Err  error
+}
+
+// GetMany busca os usuários em lotes pelo multiget de /users.
+func GetMany(c *mlapi.Client, ids []string, attributes ...string) []Result {
+	raw := c.MultiGet("https://api.mercadolibre.com/users", ids, attributes)
+
+	results := make([]Result, len(raw))
+	for i, r := range raw {
+		results[i].ID = r.ID
+		if r.Err != nil {
+			results[i].Err = r.Err
+			continue
+		}
+
+		var user User
+		err := json.Unmarshal(r.Body, &user)
+		if err != nil {
+			results[i].Err = fmt.Errorf("erro ao fazer unmarshal: %v", err)
+			continue
+		}
+		results[i].User = &user
+	}
+
+	return results
+}
@@ -0,0 +1,309 @@
+// Package period monta as janelas de datas dos relatórios: datas explícitas ou
+// períodos nomeados (mês, ciclo de faturamento, semana e trimestre), sempre no
+// fuso configurado em timezone.
+package period
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"dimi/kkalcs/dotenv"
+	"dimi/kkalcs/timezone"
+)
+
+// DefaultCutoff é o dia de corte do ciclo de faturamento quando nem o
+// parâmetro cutoff nem BILLING_CUTOFF_DAY estão definidos.
+const DefaultCutoff = 21
+
+// MaxCutoff é o maior dia de corte aceito, para que o ciclo exista em todo mês.
+const MaxCutoff = 28
+
+const dateLayout = "2006-01-02"
+
+// Range é uma janela fechada de From até To, inclusive.
+type Range struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+
+	// months é o passo em meses de Previous para os períodos que avançam por
+	// mês; isoWeek marca as semanas, que YearBefore compara pela semana ISO.
+	months  int
+	isoWeek bool
+}
+
+// String formata o período como "AAAA-MM-DD..AAAA-MM-DD" no fuso configurado.
+func (r Range) String() string {
+	return timezone.In(r.From).Format(dateLayout) + ".." + timezone.In(r.To).Format(dateLayout)
+}
+
+// Between vai do início do dia from até o fim do dia to.
+func Between(from, to time.Time) Range {
+	from = timezone.In(from)
+	to = timezone.In(to)
+	return Range{
+		From: timezone.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0),
+		To:   endOfDayBefore(timezone.Date(to.Year(), to.Month(), to.Day()+1, 0, 0, 0, 0)),
+	}
+}
+
+// Month é o mês civil.
+func Month(year int, month time.Month) Range {
+	start := timezone.Date(year, month, 1, 0, 0, 0, 0)
+	return Range{From: start, To: endOfDayBefore(start.AddDate(0, 1, 0)), months: 1}
+}
+
+// BillingCycle é o ciclo de faturamento do Mercado Livre que fecha em month:
+// do dia cutoff do mês anterior até o fim da véspera do dia cutoff de month.
+// Ciclos consecutivos não se sobrepõem.
+func BillingCycle(year int, month time.Month, cutoff int) Range {
+	end := timezone.Date(year, month, cutoff, 0, 0, 0, 0)
+	return Range{From: end.AddDate(0, -1, 0), To: endOfDayBefore(end), months: 1}
+}
+
+// Week é a semana ISO 8601 (de segunda a domingo) do ano informado.
+func Week(year, week int) Range {
+	jan4 := timezone.Date(year, time.January, 4, 0, 0, 0, 0)
+	offset := (int(jan4.Weekday()) + 6) % 7
+	start := jan4.AddDate(0, 0, -offset+(week-1)*7)
+	return Range{From: start, To: endOfDayBefore(start.AddDate(0, 0, 7)), isoWeek: true}
+}
+
+// Quarter é o trimestre civil (1 a 4).
+func Quarter(year, quarter int) Range {
+	start := timezone.Date(year, time.Month((quarter-1)*3+1), 1, 0, 0, 0, 0)
+	return Range{From: start, To: endOfDayBefore(start.AddDate(0, 3, 0)), months: 3}
+}
+
+// Previous é o período imediatamente anterior, com a mesma duração. Meses,
+// trimestres, ciclos de faturamento e o intervalo year1/month1..year2/month2
+// voltam a quantidade de meses que cobrem. Entre as datas explícitas, as que
+// começam e terminam no mesmo dia do mês também voltam meses; as demais, dias.
+func (r Range) Previous() Range {
+	if r.months > 0 {
+		return r.shift(0, -r.months, 0)
+	}
+
+	from := timezone.In(r.From)
+	end := timezone.In(r.To.Add(time.Nanosecond))
+
+	if from.Day() == end.Day() && isMidnight(from) && isMidnight(end) {
+		months := (end.Year()-from.Year())*12 + int(end.Month()-from.Month())
+		return r.shift(0, -months, 0)
+	}
+	days := int(end.Sub(from).Round(24*time.Hour) / (24 * time.Hour))
+	return r.shift(0, 0, -days)
+}
+
+// YearBefore é o mesmo período um ano antes. Uma semana ISO vira a mesma
+// semana do ano anterior, de segunda a domingo; a semana 53 vira a 52 se o
+// ano anterior não tiver 53 semanas.
+func (r Range) YearBefore() Range {
+	if r.isoWeek {
+		year, week := timezone.In(r.From).ISOWeek()
+		prev := Week(year-1, week)
+		if y, _ := prev.From.ISOWeek(); y != year-1 {
+			prev = Week(year-1, week-1)
+		}
+		return prev
+	}
+	return r.shift(-1, 0, 0)
+}
+
+func (r Range) shift(years, months, days int) Range {
+	end := timezone.In(r.To.Add(time.Nanosecond))
+	return Range{
+		From:    timezone.In(r.From).AddDate(years, months, days),
+		To:      endOfDayBefore(end.AddDate(years, months, days)),
+		months:  r.months,
+		isoWeek: r.isoWeek,
+	}
+}
+
+func isMidnight(t time.Time) bool {
+	return t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0
+}
+
+// Against monta os períodos de comparação a partir de uma lista separada por
+// vírgulas: previous (o período anterior) e last_year (o mesmo período um ano
+// antes). Vazio usa os dois.
+func Against(r Range, names string) ([]Range, error) {
+	if names == "" {
+		names = "previous,last_year"
+	}
+
+	var ranges []Range
+	for _, name := range strings.Split(names, ",") {
+		switch strings.TrimSpace(name) {
+		case "previous":
+			ranges = append(ranges, r.Previous())
+		case "last_year":
+			ranges = append(ranges, r.YearBefore())
+		default:
+			return nil, fmt.Errorf("Invalid against parameter %q. Use previous and/or last_year", name)
+		}
+	}
+	return ranges, nil
+}
+
+func endOfDayBefore(t time.Time) time.Time {
+	return t.Add(-time.Nanosecond)
+}
+
+// Cutoff retorna o dia de corte configurado em BILLING_CUTOFF_DAY, ou
+// DefaultCutoff se ele não estiver definido ou for inválido.
+func Cutoff() int {
+	cutoff, err := strconv.Atoi(dotenv.Get("BILLING_CUTOFF_DAY"))
+	if err != nil || cutoff < 1 || cutoff > MaxCutoff {
+		return DefaultCutoff
+	}
+	return cutoff
+}
+
+// Parse lê o período dos parâmetros, em uma das formas:
+//
+//   - from e to (AAAA-MM-DD, inclusive);
+//   - period=month com year e month;
+//   - period=billing com year, month e cutoff opcional;
+//   - period=week com year e week (ISO 8601);
+//   - period=quarter com year e quarter;
+//   - year1, month1, year2 e month2, do dia de corte de month1 até o fim do
+//     dia de corte de month2, mantido por compatibilidade.
+func Parse(query url.Values) (Range, error) {
+	switch {
+	case query.Get("from") != "" || query.Get("to") != "":
+		return parseBetween(query)
+	case query.Get("period") != "":
+		return parseNamed(query)
+	case query.Get("year1") != "" || query.Get("month1") != "" || query.Get("year2") != "" || query.Get("month2") != "":
+		return parseLegacy(query)
+	default:
+		return Range{}, errors.New("Missing period. Use from and to (YYYY-MM-DD) or period (month, billing, week, quarter)")
+	}
+}
+
+func parseBetween(query url.Values) (Range, error) {
+	fromStr, toStr := query.Get("from"), query.Get("to")
+	if fromStr == "" || toStr == "" {
+		return Range{}, errors.New("Both from and to are required (YYYY-MM-DD)")
+	}
+	from, err := timezone.ParseDate(fromStr)
+	if err != nil {
+		return Range{}, fmt.Errorf("Invalid from parameter %q. Use YYYY-MM-DD", fromStr)
+	}
+	to, err := timezone.ParseDate(toStr)
+	if err != nil {
+		return Range{}, fmt.Errorf("Invalid to parameter %q. Use YYYY-MM-DD", toStr)
+	}
+	if to.Before(from) {
+		return Range{}, errors.New("Invalid date range: from is after to")
+	}
+	return Between(from, to), nil
+}
+
+func parseNamed(query url.Values) (Range, error) {
+	name := query.Get("period")
+	switch name {
+	case "month", "billing", "week", "quarter":
+	default:
+		return Range{}, fmt.Errorf("Invalid period parameter %q. Use month, billing, week or quarter", name)
+	}
+	year, err := intParam(query, "year", 1, 9999)
+	if err != nil {
+		return Range{}, err
+	}
+
+	switch name {
+	case "month":
+		month, err := intParam(query, "month", 1, 12)
+		if err != nil {
+			return Range{}, err
+		}
+		return Month(year, time.Month(month)), nil
+	case "billing":
+		month, err := intParam(query, "month", 1, 12)
+		if err != nil {
+			return Range{}, err
+		}
+		cutoff, err := cutoffParam(query)
+		if err != nil {
+			return Range{}, err
+		}
+		return BillingCycle(year, time.Month(month), cutoff), nil
+	case "week":
+		week, err := intParam(query, "week", 1, 53)
+		if err != nil {
+			return Range{}, err
+		}
+		r := Week(year, week)
+		if y, _ := r.From.ISOWeek(); y != year {
+			return Range{}, fmt.Errorf("Invalid week parameter: %d has no week %d", year, week)
+		}
+		return r, nil
+	default: // quarter
+		quarter, err := intParam(query, "quarter", 1, 4)
+		if err != nil {
+			return Range{}, err
+		}
+		return Quarter(year, quarter), nil
+	}
+}
+
+func parseLegacy(query url.Values) (Range, error) {
+	year1, err := intParam(query, "year1", 1, 9999)
+	if err != nil {
+		return Range{}, err
+	}
+	month1, err := intParam(query, "month1", 1, 12)
+	if err != nil {
+		return Range{}, err
+	}
+	year2, err := intParam(query, "year2", 1, 9999)
+	if err != nil {
+		return Range{}, err
+	}
+	month2, err := intParam(query, "month2", 1, 12)
+	if err != nil {
+		return Range{}, err
+	}
+	if year1 > year2 || (year1 == year2 && month1 > month2) {
+		return Range{}, errors.New("Invalid date range")
+	}
+	cutoff, err := cutoffParam(query)
+	if err != nil {
+		return Range{}, err
+	}
+
+	r := Between(
+		timezone.Date(year1, time.Month(month1), cutoff, 0, 0, 0, 0),
+		timezone.Date(year2, time.Month(month2), cutoff, 0, 0, 0, 0),
+	)
+	// O intervalo inclui o dia de corte nas duas pontas e não dura um número
+	// inteiro de meses; o anterior é o mesmo intervalo deslocado pelos meses
+	// que ele cobre.
+	r.months = (year2-year1)*12 + month2 - month1
+	return r, nil
+}
+
+func cutoffParam(query url.Values) (int, error) {
+	if query.Get("cutoff") == "" {
+		return Cutoff(), nil
+	}
+	return intParam(query, "cutoff", 1, MaxCutoff)
+}
+
+// intParam lê um parâmetro inteiro obrigatório entre min e max.
+func intParam(query url.Values, name string, min, max int) (int, error) {
+	s := query.Get(name)
+	if s == "" {
+		return 0, fmt.Errorf("Missing %s parameter", name)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min || n > max {
+		return 0, fmt.Errorf("Invalid %s parameter %q. Must be between %d and %d", name, s, min, max)
+	}
+	return n, nil
+}
@@ -0,0 +1,158 @@
+package period
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestConstructors(t *testing.T) {
+	tests := []struct {
+		name string
+		r    Range
+		want string
+	}{
+		{"month", Month(2025, time.February), "2025-02-01..2025-02-28"},
+		{"leap month", Month(2024, time.February), "2024-02-01..2024-02-29"},
+		{"billing cycle", BillingCycle(2025, time.March, 21), "2025-02-21..2025-03-20"},
+		{"billing cycle across years", BillingCycle(2025, time.January, 21), "2024-12-21..2025-01-20"},
+		{"iso week starting in previous year", Week(2025, 1), "2024-12-30..2025-01-05"},
+		{"iso week 53", Week(2020, 53), "2020-12-28..2021-01-03"},
+		{"quarter", Quarter(2025, 4), "2025-10-01..2025-12-31"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.r.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if end := tt.r.To.Add(time.Nanosecond).In(tt.r.From.Location()); !isMidnight(end) {
+				t.Errorf("To %v is not the end of a day", tt.r.To)
+			}
+		})
+	}
+}
+
+func TestPrevious(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"month", "period=month&year=2025&month=3", "2025-02-01..2025-02-28"},
+		{"month across years", "period=month&year=2025&month=1", "2024-12-01..2024-12-31"},
+		{"billing cycle", "period=billing&year=2025&month=3&cutoff=21", "2025-01-21..2025-02-20"},
+		{"quarter", "period=quarter&year=2025&quarter=1", "2024-10-01..2024-12-31"},
+		{"iso week", "period=week&year=2025&week=1", "2024-12-23..2024-12-29"},
+		{"whole months between dates", "from=2025-01-01&to=2025-03-31", "2024-10-01..2024-12-31"},
+		{"days between dates", "from=2025-03-05&to=2025-03-11", "2025-02-26..2025-03-04"},
+		{"legacy cycle", "year1=2025&month1=2&year2=2025&month2=3&cutoff=21", "2025-01-21..2025-02-21"},
+		{"legacy across years", "year1=2024&month1=12&year2=2025&month2=2&cutoff=21", "2024-10-21..2024-12-21"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mustParse(t, tt.query)
+			if got := r.Previous().String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestYearBefore(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"month", "period=month&year=2025&month=3", "2024-03-01..2024-03-31"},
+		{"leap day", "from=2024-02-01&to=2024-02-29", "2023-02-01..2023-02-28"},
+		{"billing cycle", "period=billing&year=2025&month=3&cutoff=21", "2024-02-21..2024-03-20"},
+		{"iso week", "period=week&year=2025&week=10", "2024-03-04..2024-03-10"},
+		{"iso week 1", "period=week&year=2025&week=1", "2024-01-01..2024-01-07"},
+		{"iso week 53 into a 52-week year", "period=week&year=2020&week=53", "2019-12-23..2019-12-29"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := mustParse(t, tt.query)
+			got := r.YearBefore()
+			if got.String() != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+			if r.isoWeek && got.From.Weekday() != time.Monday {
+				t.Errorf("week starts on %s, want Monday", got.From.Weekday())
+			}
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		query   string
+		want    string
+		wantErr bool
+	}{
+		{"between", "from=2025-03-01&to=2025-03-15", "2025-03-01..2025-03-15", false},
+		{"single day", "from=2025-03-01&to=2025-03-01", "2025-03-01..2025-03-01", false},
+		{"legacy", "year1=2025&month1=2&year2=2025&month2=3&cutoff=21", "2025-02-21..2025-03-21", false},
+		{"missing period", "", "", true},
+		{"missing to", "from=2025-03-01", "", true},
+		{"from after to", "from=2025-03-02&to=2025-03-01", "", true},
+		{"bad date", "from=2025-13-01&to=2025-03-01", "", true},
+		{"unknown period", "period=year&year=2025", "", true},
+		{"month out of range", "period=month&year=2025&month=13", "", true},
+		{"week 53 in a 52-week year", "period=week&year=2025&week=53", "", true},
+		{"cutoff above max", "period=billing&year=2025&month=3&cutoff=29", "", true},
+		{"legacy reversed", "year1=2025&month1=3&year2=2025&month2=2", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, err := url.ParseQuery(tt.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r, err := Parse(query)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %s", r)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := r.String(); got != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgainst(t *testing.T) {
+	r := Month(2025, time.March)
+
+	ranges, err := Against(r, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ranges) != 2 || ranges[0].String() != "2025-02-01..2025-02-28" || ranges[1].String() != "2024-03-01..2024-03-31" {
+		t.Errorf("got %v", ranges)
+	}
+
+	if _, err := Against(r, "previous,next"); err == nil {
+		t.Error("expected an error for an unknown comparison")
+	}
+}
+
+func mustParse(t *testing.T, query string) Range {
+	t.Helper()
+	values, err := url.ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := Parse(values)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", query, err)
+	}
+	return r
+}
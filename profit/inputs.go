@@ -0,0 +1,65 @@
+package profit
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"dimi/kkalcs/cogs"
+	"dimi/kkalcs/mlapi/ads"
+	"dimi/kkalcs/mlapi/claims"
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/shipments"
+	"dimi/kkalcs/taxes"
+)
+
+// Store é onde ficam salvos os dados usados pelo Build além dos pedidos.
+type Store interface {
+	ShipmentCosts(shipmentIDs []string) (map[string]shipments.ShipmentCost, error)
+	UnitCosts() (*cogs.Table, error)
+	AdMetricsBetween(dateFrom, dateTo time.Time) ([]ads.DailyMetric, error)
+	ClaimsForOrders(orderIDs []int64) ([]claims.Claim, error)
+}
+
+// LoadInputs monta as entradas do Build para os pedidos do período com o que
+// está salvo no store: custos de envio, custos unitários, anúncios e
+// reclamações. rules pode ser nil.
+func LoadInputs(s Store, ords []orders.Order, dateFrom, dateTo time.Time, rules *taxes.Rules) (Inputs, error) {
+	in := Inputs{Orders: ords, Taxes: rules}
+
+	var shippingIDs []string
+	ids := make([]int64, 0, len(ords))
+	for _, pack := range orders.GroupPacks(ords) {
+		if pack.ShippingID != 0 {
+			shippingIDs = append(shippingIDs, strconv.Itoa(pack.ShippingID))
+		}
+	}
+	for _, o := range ords {
+		ids = append(ids, o.OrderID)
+	}
+
+	var err error
+	in.ShipmentCosts, err = s.ShipmentCosts(shippingIDs)
+	if err != nil {
+		return in, fmt.Errorf("erro ao ler custos de envio: %s", err)
+	}
+	in.Costs, err = s.UnitCosts()
+	if err != nil {
+		return in, fmt.Errorf("erro ao ler custos: %s", err)
+	}
+
+	metrics, err := s.AdMetricsBetween(dateFrom, dateTo)
+	if err != nil {
+		return in, fmt.Errorf("erro ao ler métricas de anúncios: %s", err)
+	}
+	in.Ads = ads.Attribute(ords, metrics)
+
+	cs, err := s.ClaimsForOrders(ids)
+	if err != nil {
+		return in, fmt.Errorf("erro ao ler reclamações: %s", err)
+	}
+	deductions := claims.Deductions(ords, cs, orders.Monthly)
+	in.Claims = &deductions
+
+	return in, nil
+}
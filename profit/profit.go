@@ -0,0 +1,283 @@
+package profit
+
+import (
+	"fmt"
+	"maps"
+	"sort"
+	"strconv"
+
+	"dimi/kkalcs/cogs"
+	"dimi/kkalcs/mlapi/ads"
+	"dimi/kkalcs/mlapi/claims"
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/shipments"
+	"dimi/kkalcs/taxes"
+)
+
+// Component é uma parcela do resultado de uma venda.
+type Component string
+
+const (
+	GrossSale      Component = "gross_sale"
+	SaleFee        Component = "sale_fee"
+	Shipping       Component = "shipping"
+	SellerDiscount Component = "seller_discount"
+	Ads            Component = "ads"
+	Taxes          Component = "taxes"
+	COGS           Component = "cogs"
+	Refunds        Component = "refunds"
+)
+
+// Entry é um lançamento do ledger. Amount é positivo para receita e negativo para
+// custos, e Source diz de onde o valor veio (endpoint e ID, ou arquivo de regras).
+type Entry struct {
+	Component Component `json:"component"`
+	Amount    float64   `json:"amount"`
+	Source    string    `json:"source"`
+}
+
+// Line é o resultado de um item de um pedido.
+type Line struct {
+	OrderID       int64   `json:"order_id"`
+	PackID        int64   `json:"pack_id"`
+	ItemID        string  `json:"item_id"`
+	SKU           string  `json:"seller_sku"`
+	CategoryID    string  `json:"category_id"`
+	ListingTypeID string  `json:"listing_type_id"`
+	Period        string  `json:"period"`
+	Quantity      int     `json:"quantity"`
+	Entries       []Entry `json:"entries"`
+	// MissingCost indica que o SKU não tinha custo cadastrado na data da venda.
+	MissingCost bool `json:"missing_cost"`
+}
+
+func (l *Line) add(c Component, amount float64, source string) {
+	if amount == 0 {
+		return
+	}
+	l.Entries = append(l.Entries, Entry{Component: c, Amount: amount, Source: source})
+}
+
+// Amount soma os lançamentos do componente.
+func (l Line) Amount(c Component) float64 {
+	var total float64
+	for _, e := range l.Entries {
+		if e.Component == c {
+			total += e.Amount
+		}
+	}
+	return total
+}
+
+func (l Line) Net() float64 {
+	var total float64
+	for _, e := range l.Entries {
+		total += e.Amount
+	}
+	return total
+}
+
+type OrderLedger struct {
+	OrderID int64  `json:"order_id"`
+	PackID  int64  `json:"pack_id"`
+	Period  string `json:"period"`
+	Lines   []Line `json:"lines"`
+}
+
+func (o OrderLedger) Net() float64 {
+	var total float64
+	for _, l := range o.Lines {
+		total += l.Net()
+	}
+	return total
+}
+
+type Ledger struct {
+	Orders []OrderLedger `json:"orders"`
+	// UnallocatedAds tem uma linha por item com gasto em anúncios e nenhuma
+	// venda entre os pedidos, para que esse gasto não suma do resultado. As
+	// linhas não têm pedido (OrderID zero) nem período.
+	UnallocatedAds []Line `json:"unallocated_ads"`
+}
+
+// Lines retorna todas as linhas do ledger, incluindo as de UnallocatedAds.
+func (l Ledger) Lines() []Line {
+	var lines []Line
+	for _, o := range l.Orders {
+		lines = append(lines, o.Lines...)
+	}
+	return append(lines, l.UnallocatedAds...)
+}
+
+// Inputs reúne os dados de cada fonte. Só Orders é obrigatório; componentes sem
+// dados ficam fora do ledger.
+type Inputs struct {
+	Orders []orders.Order
+	// ShipmentCosts indexado pelo ID do envio, como em shipments.ShipmentCost.ShipmentID.
+	ShipmentCosts map[string]shipments.ShipmentCost
+	Ads           []ads.ItemAds
+	Taxes         *taxes.Rules
+	Costs         *cogs.Table
+	Claims        *claims.Report
+	// Period define o período de cada pedido. Se nil, usa orders.Monthly.
+	Period func(orders.Order) string
+}
+
+// Build monta o ledger de cada pedido. Custos cobrados por envio são rateados
+// entre os itens do pack, e o gasto com anúncios é rateado por unidade vendida;
+// o de itens sem venda vai para Ledger.UnallocatedAds.
+func Build(in Inputs) Ledger {
+	period := in.Period
+	if period == nil {
+		period = orders.Monthly
+	}
+
+	adsByItem := make(map[string]ads.ItemAds)
+	for _, ia := range in.Ads {
+		adsByItem[ia.ItemID] = ia
+	}
+
+	var ledger Ledger
+	for _, pack := range orders.GroupPacks(in.Orders) {
+		shippingID := strconv.Itoa(pack.ShippingID)
+		var shippingCost float64
+		if cost, ok := in.ShipmentCosts[shippingID]; ok {
+			shippingCost = cost.FinalCost
+		}
+		allocs := pack.Allocate(shippingCost)
+
+		i := 0
+		for _, order := range pack.Orders {
+			ol := OrderLedger{OrderID: order.OrderID, PackID: pack.ID, Period: period(order)}
+			orderSource := fmt.Sprintf("orders/%d", order.OrderID)
+
+			discounts := order.SellerDiscountByLine()
+
+			var refunds claims.Deduction
+			var restocked map[string]int
+			if in.Claims != nil {
+				refunds = in.Claims.ByOrder[order.OrderID]
+				restocked = maps.Clone(in.Claims.RestockedByOrder[order.OrderID])
+			}
+			orderGross := order.Gross()
+
+			at := order.DateCreated
+			for j, item := range order.Items {
+				alloc := allocs[i]
+				i++
+
+				line := Line{
+					OrderID:       order.OrderID,
+					PackID:        pack.ID,
+					ItemID:        item.ItemID,
+					SKU:           item.SKU,
+					CategoryID:    item.CategoryID,
+					ListingTypeID: item.ListingTypeID,
+					Period:        ol.Period,
+					Quantity:      item.Quantity,
+				}
+
+				gross := item.Gross()
+				line.add(GrossSale, gross, orderSource)
+				line.add(SaleFee, -item.Fee(), orderSource)
+				line.add(Shipping, -alloc.Shipping, fmt.Sprintf("shipments/%s/costs", shippingID))
+				line.add(SellerDiscount, -discounts[j], orderSource+"/discounts")
+
+				if ia, ok := adsByItem[item.ItemID]; ok {
+					line.add(Ads, -ia.SpendPerUnit()*float64(item.Quantity), "advertising/product_ads/"+item.ItemID)
+				}
+				if in.Taxes != nil {
+					line.add(Taxes, -gross*in.Taxes.RateFor(item.SKU), "taxes/"+in.Taxes.Regime.Name())
+				}
+				if in.Costs != nil {
+					unitCost, ok := in.Costs.UnitCost(item.SKU, at)
+					line.MissingCost = !ok
+					line.add(COGS, -unitCost*float64(item.Quantity), "cogs/"+item.SKU)
+				}
+				if refunds.Claims > 0 && orderGross > 0 {
+					share := gross / orderGross
+					line.add(Refunds, -refunds.Total()*share, "post-purchase/claims?order="+strconv.FormatInt(order.OrderID, 10))
+				}
+				// As unidades reestocadas voltam a ter valor, então o custo delas é
+				// estornado. Se o item aparecer em mais de uma linha, elas são
+				// descontadas linha a linha até acabarem.
+				if units := min(restocked[item.ItemID], item.Quantity); units > 0 && in.Costs != nil {
+					restocked[item.ItemID] -= units
+					unitCost, _ := in.Costs.UnitCost(item.SKU, at)
+					line.add(COGS, unitCost*float64(units), "post-purchase/claims?order="+strconv.FormatInt(order.OrderID, 10))
+				}
+
+				ol.Lines = append(ol.Lines, line)
+			}
+
+			ledger.Orders = append(ledger.Orders, ol)
+		}
+	}
+
+	for _, ia := range in.Ads {
+		if ia.Units > 0 || ia.Spend == 0 {
+			continue
+		}
+		line := Line{ItemID: ia.ItemID}
+		line.add(Ads, -ia.Spend, "advertising/product_ads/"+ia.ItemID)
+		ledger.UnallocatedAds = append(ledger.UnallocatedAds, line)
+	}
+	sort.Slice(ledger.UnallocatedAds, func(i, j int) bool {
+		return ledger.UnallocatedAds[i].ItemID < ledger.UnallocatedAds[j].ItemID
+	})
+
+	return ledger
+}
+
+// Totals é a soma de um grupo de linhas do ledger.
+type Totals struct {
+	Lines   int                   `json:"lines"`
+	Units   int                   `json:"units"`
+	Amounts map[Component]float64 `json:"amounts"`
+	Net     float64               `json:"net"`
+}
+
+// Rollup agrupa as linhas do ledger pela chave.
+func (l Ledger) Rollup(key func(Line) string) map[string]Totals {
+	result := make(map[string]Totals)
+	for _, line := range l.Lines() {
+		k := key(line)
+		t, ok := result[k]
+		if !ok {
+			t.Amounts = make(map[Component]float64)
+		}
+		t.Lines++
+		t.Units += line.Quantity
+		for _, e := range line.Entries {
+			t.Amounts[e.Component] += e.Amount
+		}
+		t.Net += line.Net()
+		result[k] = t
+	}
+	return result
+}
+
+func (l Ledger) ByItem() map[string]Totals {
+	return l.Rollup(func(line Line) string { return line.ItemID })
+}
+
+func (l Ledger) BySKU() map[string]Totals {
+	return l.Rollup(func(line Line) string { return line.SKU })
+}
+
+func (l Ledger) ByCategory() map[string]Totals {
+	return l.Rollup(func(line Line) string { return line.CategoryID })
+}
+
+func (l Ledger) ByListingType() map[string]Totals {
+	return l.Rollup(func(line Line) string { return line.ListingTypeID })
+}
+
+func (l Ledger) ByPeriod() map[string]Totals {
+	return l.Rollup(func(line Line) string { return line.Period })
+}
+
+// Total soma o ledger inteiro.
+func (l Ledger) Total() Totals {
+	return l.Rollup(func(Line) string { return "" })[""]
+}
@@ -0,0 +1,56 @@
+package profit
+
+import (
+	"testing"
+	"time"
+
+	"dimi/kkalcs/cogs"
+	"dimi/kkalcs/mlapi/claims"
+	"dimi/kkalcs/mlapi/orders"
+)
+
+func TestBuildCreditsRestockedCOGS(t *testing.T) {
+	created := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+	order := orders.Order{
+		OrderID:     1,
+		DateCreated: created,
+		Items: []orders.OrderItem{
+			{ItemID: "MLB1", SKU: "A", Quantity: 1, UnitPrice: 100},
+			{ItemID: "MLB1", SKU: "A", Quantity: 2, UnitPrice: 100},
+			{ItemID: "MLB2", SKU: "B", Quantity: 1, UnitPrice: 50},
+		},
+	}
+	costs := cogs.NewTable([]cogs.UnitCost{
+		{SKU: "A", Cost: 10, EffectiveFrom: created.AddDate(0, -1, 0)},
+		{SKU: "B", Cost: 5, EffectiveFrom: created.AddDate(0, -1, 0)},
+	})
+
+	tests := []struct {
+		name      string
+		restocked map[string]int
+		want      []float64
+	}{
+		{name: "nothing restocked", want: []float64{-10, -20, -5}},
+		{name: "restocked units spread over the item lines", restocked: map[string]int{"MLB1": 2}, want: []float64{0, -10, -5}},
+		{name: "restocked above the sold quantity", restocked: map[string]int{"MLB1": 5, "MLB2": 1}, want: []float64{0, 0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := claims.Report{
+				ByOrder:          map[int64]claims.Deduction{1: {Claims: 1}},
+				RestockedByOrder: map[int64]map[string]int{1: tt.restocked},
+			}
+			ledger := Build(Inputs{Orders: []orders.Order{order}, Costs: costs, Claims: &report})
+
+			lines := ledger.Orders[0].Lines
+			if len(lines) != len(tt.want) {
+				t.Fatalf("got %d lines, want %d", len(lines), len(tt.want))
+			}
+			for i, line := range lines {
+				if got := line.Amount(COGS); got != tt.want[i] {
+					t.Errorf("line %d: got COGS %v, want %v", i, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
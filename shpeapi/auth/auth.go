@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
@@ -19,6 +20,7 @@ import (
 	"time"
 
 	"dimi/kkalcs/dotenv"
+	"dimi/kkalcs/logger"
 )
 
 type oAuthResponse struct {
@@ -201,15 +203,15 @@ func ExchangeCodeForToken(code string, shopID int64) (*oAuthResponse, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", logger.RedactError(err))
 	}
 	defer resp.Body.Close()
 
 	respBody, _ := io.ReadAll(resp.Body)
-	fmt.Println("Shopee API Raw Response (Initial Token):", string(respBody))
+	slog.Debug("Shopee API raw response (initial token)", "body", logger.Redact(string(respBody)))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: %s - %s", resp.Status, string(respBody))
+		return nil, fmt.Errorf("API error: %s - %s", resp.Status, logger.Redact(string(respBody)))
 	}
 
 	var shopeeResponse ShopeeAuthResponse
@@ -263,15 +265,15 @@ func ExchangeRefreshToken(refreshToken string, shopID int64) (*oAuthResponse, er
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", logger.RedactError(err))
 	}
 	defer resp.Body.Close()
 
 	respBody, _ := io.ReadAll(resp.Body)
-	fmt.Println("Shopee API Raw Response (Refresh):", string(respBody))
+	slog.Debug("Shopee API raw response (refresh)", "body", logger.Redact(string(respBody)))
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: %s - %s", resp.Status, string(respBody))
+		return nil, fmt.Errorf("API error: %s - %s", resp.Status, logger.Redact(string(respBody)))
 	}
 
 	var shopeeResponse ShopeeAuthResponse
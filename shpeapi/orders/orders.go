@@ -5,12 +5,14 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
 	"dimi/kkalcs/dotenv"
+	"dimi/kkalcs/logger"
 	"dimi/kkalcs/shpeapi/auth"
 )
 
@@ -87,14 +89,14 @@ func GetOrderList() (*GetOrderListResponse, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", logger.RedactError(err))
 	}
 	defer resp.Body.Close()
 
 	respBody, _ := io.ReadAll(resp.Body)
-	fmt.Println("Response Body:", string(respBody))
+	slog.Debug("Shopee order list response", "body", logger.Redact(string(respBody)))
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: %s - %s", resp.Status, string(respBody))
+		return nil, fmt.Errorf("API error: %s - %s", resp.Status, logger.Redact(string(respBody)))
 	}
 
 	// 6. Unmarshal the response
@@ -193,13 +195,13 @@ func GetOrderDetail(orderSNs []string) (*GetOrderDetailResponse, error) {
 	client := &http.Client{}
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to send request: %w", err)
+		return nil, fmt.Errorf("failed to send request: %w", logger.RedactError(err))
 	}
 	defer resp.Body.Close()
 
 	respBody, _ := io.ReadAll(resp.Body)
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API error: %s - %s", resp.Status, string(respBody))
+		return nil, fmt.Errorf("API error: %s - %s", resp.Status, logger.Redact(string(respBody)))
 	}
 
 	// 6. Unmarshal the response
@@ -254,7 +256,7 @@ func Chance() {
 	for _, detail := range detailResponse.Response.OrderList {
 		fmt.Printf("Order SN: %s\n", detail.OrderSN)
 		fmt.Printf("  Status: %s\n", detail.OrderStatus)
-		fmt.Printf("  Buyer: %s\n", detail.RecipientAddress.Name)
+		fmt.Printf("  Buyer: %s\n", logger.Mask(detail.RecipientAddress.Name))
 		fmt.Printf("  Items:\n")
 		for _, item := range detail.ItemList {
 			fmt.Printf("    - %s (Qty: %d)\n", item.ItemName, item.Quantity)
@@ -0,0 +1,425 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"dimi/kkalcs/cogs"
+	"dimi/kkalcs/mlapi/ads"
+	"dimi/kkalcs/mlapi/categories"
+	"dimi/kkalcs/mlapi/claims"
+	"dimi/kkalcs/mlapi/items"
+	"dimi/kkalcs/mlapi/orders"
+	"dimi/kkalcs/mlapi/questions"
+	"dimi/kkalcs/mlapi/shipments"
+	"dimi/kkalcs/timezone"
+)
+
+// Buckets do banco. Os valores são gravados em JSON, com a chave sendo o ID do
+// recurso. ordersByDate é um índice "data UTC + order_id" para buscas por período.
+var (
+	bucketOrders        = []byte("orders")
+	bucketOrdersByDate  = []byte("orders_by_date")
+	bucketItems         = []byte("items")
+	bucketShipments     = []byte("shipments")
+	bucketShipmentCosts = []byte("shipment_costs")
+	bucketCategories    = []byte("categories")
+	bucketUnitCosts     = []byte("unit_costs")
+	bucketAdMetrics     = []byte("ad_metrics")
+	bucketClaims        = []byte("claims")
+	bucketQuestions     = []byte("questions")
+	bucketMeta          = []byte("meta")
+
+	keyHighWaterMark = []byte("orders_high_water_mark")
+)
+
+const indexDateLayout = "2006-01-02T15:04:05Z"
+
+// DB é o banco local, embutido, com pedidos, envios, custos e categorias.
+type DB struct {
+	bolt *bolt.DB
+}
+
+func Open(path string) (*DB, error) {
+	b, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("erro ao abrir banco: %s", err)
+	}
+
+	err = b.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketOrders, bucketOrdersByDate, bucketItems, bucketShipments, bucketShipmentCosts, bucketCategories, bucketUnitCosts, bucketAdMetrics, bucketClaims, bucketQuestions, bucketMeta} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		b.Close()
+		return nil, fmt.Errorf("erro ao criar buckets: %s", err)
+	}
+
+	return &DB{bolt: b}, nil
+}
+
+func (db *DB) Close() error {
+	return db.bolt.Close()
+}
+
+func orderKey(id int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+func dateKey(date time.Time, id int64) []byte {
+	return append([]byte(date.UTC().Format(indexDateLayout)), orderKey(id)...)
+}
+
+func put(b *bolt.Bucket, key []byte, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return b.Put(key, data)
+}
+
+// UpsertOrders grava ou substitui os pedidos, atualizando o índice por data.
+// Pedidos sem Discounts mantêm os descontos já gravados: a busca de pedidos
+// não os traz, só AttachDiscounts. Pedidos sem data de criação não podem ser
+// indexados, então são ignorados e registrados no log.
+func (db *DB) UpsertOrders(ords []orders.Order) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketOrders)
+		idx := tx.Bucket(bucketOrdersByDate)
+
+		for _, order := range ords {
+			if order.DateCreated.IsZero() {
+				slog.Warn("Pedido sem data de criação ignorado", "order_id", order.OrderID)
+				continue
+			}
+			key := orderKey(order.OrderID)
+
+			// Remove a entrada antiga do índice caso a data tenha mudado.
+			if old := b.Get(key); old != nil {
+				var prev orders.Order
+				if err := json.Unmarshal(old, &prev); err == nil {
+					if order.Discounts == nil {
+						order.Discounts = prev.Discounts
+					}
+					if !prev.DateCreated.IsZero() {
+						if err := idx.Delete(dateKey(prev.DateCreated, prev.OrderID)); err != nil {
+							return err
+						}
+					}
+				}
+			}
+
+			if err := put(b, key, order); err != nil {
+				return err
+			}
+			if err := idx.Put(dateKey(order.DateCreated, order.OrderID), key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Order retorna o pedido pelo ID, ou nil se ele não estiver no banco.
+func (db *DB) Order(id int64) (*orders.Order, error) {
+	var order *orders.Order
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketOrders).Get(orderKey(id))
+		if data == nil {
+			return nil
+		}
+		order = &orders.Order{}
+		return json.Unmarshal(data, order)
+	})
+	return order, err
+}
+
+// OrdersBetween retorna os pedidos criados entre dateFrom e dateTo (inclusive),
+// em ordem de criação.
+func (db *DB) OrdersBetween(dateFrom, dateTo time.Time) ([]orders.Order, error) {
+	var ords []orders.Order
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketOrders)
+		c := tx.Bucket(bucketOrdersByDate).Cursor()
+
+		from := []byte(dateFrom.UTC().Format(indexDateLayout))
+		to := dateTo.UTC().Format(indexDateLayout)
+
+		for k, v := c.Seek(from); k != nil && string(k[:len(indexDateLayout)]) <= to; k, v = c.Next() {
+			var order orders.Order
+			if err := json.Unmarshal(b.Get(v), &order); err != nil {
+				return err
+			}
+			ords = append(ords, order)
+		}
+		return nil
+	})
+	return ords, err
+}
+
+// LastSync e Merge fazem o DB implementar orders.SyncStore.
+func (db *DB) LastSync() (time.Time, error) {
+	var t time.Time
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketMeta).Get(keyHighWaterMark)
+		if data == nil {
+			return nil
+		}
+		return t.UnmarshalText(data)
+	})
+	return t, err
+}
+
+func (db *DB) Merge(ords []orders.Order, syncedAt time.Time) error {
+	err := db.UpsertOrders(ords)
+	if err != nil {
+		return err
+	}
+
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		data, err := syncedAt.UTC().MarshalText()
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(bucketMeta).Put(keyHighWaterMark, data)
+	})
+}
+
+func (db *DB) UpsertItems(its []items.Item) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketItems)
+		for _, item := range its {
+			if err := put(b, []byte(item.ID), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) Item(id string) (*items.Item, error) {
+	var item *items.Item
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketItems).Get([]byte(id))
+		if data == nil {
+			return nil
+		}
+		item = &items.Item{}
+		return json.Unmarshal(data, item)
+	})
+	return item, err
+}
+
+// UpsertShipment guarda a qual pedido pertence um envio.
+func (db *DB) UpsertShipment(shipmentID string, orderID int64) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketShipments).Put([]byte(shipmentID), []byte(strconv.FormatInt(orderID, 10)))
+	})
+}
+
+// ShipmentOrder retorna o pedido do envio, ou 0 se o envio não estiver no banco.
+func (db *DB) ShipmentOrder(shipmentID string) (int64, error) {
+	var orderID int64
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketShipments).Get([]byte(shipmentID))
+		if data == nil {
+			return nil
+		}
+		var err error
+		orderID, err = strconv.ParseInt(string(data), 10, 64)
+		return err
+	})
+	return orderID, err
+}
+
+func (db *DB) UpsertShipmentCosts(costs []shipments.ShipmentCost) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketShipmentCosts)
+		for _, cost := range costs {
+			if err := put(b, []byte(cost.ShipmentID), cost); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ShipmentCosts retorna os custos dos envios encontrados no banco, indexados pelo
+// ID. Os IDs que não estão no banco ficam de fora do mapa.
+func (db *DB) ShipmentCosts(shipmentIDs []string) (map[string]shipments.ShipmentCost, error) {
+	costs := make(map[string]shipments.ShipmentCost)
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketShipmentCosts)
+		for _, id := range shipmentIDs {
+			data := b.Get([]byte(id))
+			if data == nil {
+				continue
+			}
+			var cost shipments.ShipmentCost
+			if err := json.Unmarshal(data, &cost); err != nil {
+				return err
+			}
+			costs[id] = cost
+		}
+		return nil
+	})
+	return costs, err
+}
+
+func (db *DB) UpsertCategories(cats []categories.Category) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketCategories)
+		for _, cat := range cats {
+			if err := put(b, []byte(cat.ID), cat); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) Categories() ([]categories.Category, error) {
+	var cats []categories.Category
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketCategories).ForEach(func(k, v []byte) error {
+			var cat categories.Category
+			if err := json.Unmarshal(v, &cat); err != nil {
+				return err
+			}
+			cats = append(cats, cat)
+			return nil
+		})
+	})
+	return cats, err
+}
+
+// UpsertUnitCosts grava os custos unitários. A chave é o SKU mais a data de
+// início, então o mesmo custo reimportado substitui o anterior.
+func (db *DB) UpsertUnitCosts(costs []cogs.UnitCost) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketUnitCosts)
+		for _, cost := range costs {
+			key := []byte(cost.SKU + "|" + cost.EffectiveFrom.UTC().Format(indexDateLayout))
+			if err := put(b, key, cost); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// UnitCosts monta a tabela de custos com tudo o que está no banco.
+func (db *DB) UnitCosts() (*cogs.Table, error) {
+	var costs []cogs.UnitCost
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketUnitCosts).ForEach(func(k, v []byte) error {
+			var cost cogs.UnitCost
+			if err := json.Unmarshal(v, &cost); err != nil {
+				return err
+			}
+			costs = append(costs, cost)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return cogs.NewTable(costs), nil
+}
+
+// UpsertAdMetrics grava as métricas diárias de anúncios. A chave começa pela
+// data, para buscas por período.
+func (db *DB) UpsertAdMetrics(metrics []ads.DailyMetric) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketAdMetrics)
+		for _, m := range metrics {
+			key := []byte(fmt.Sprintf("%s|%s|%d", m.Date, m.ItemID, m.CampaignID))
+			if err := put(b, key, m); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// AdMetricsBetween retorna as métricas dos dias entre dateFrom e dateTo
+// (inclusive), no fuso configurado.
+func (db *DB) AdMetricsBetween(dateFrom, dateTo time.Time) ([]ads.DailyMetric, error) {
+	var metrics []ads.DailyMetric
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketAdMetrics).Cursor()
+
+		from := timezone.In(dateFrom).Format("2006-01-02")
+		to := timezone.In(dateTo).Format("2006-01-02")
+
+		for k, v := c.Seek([]byte(from)); k != nil && string(k[:len(to)]) <= to; k, v = c.Next() {
+			var m ads.DailyMetric
+			if err := json.Unmarshal(v, &m); err != nil {
+				return err
+			}
+			metrics = append(metrics, m)
+		}
+		return nil
+	})
+	return metrics, err
+}
+
+// UpsertClaims grava as reclamações, com a devolução se ela foi anexada.
+func (db *DB) UpsertClaims(cs []claims.Claim) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketClaims)
+		for _, claim := range cs {
+			if err := put(b, orderKey(claim.ID), claim); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (db *DB) UpsertQuestions(qs []questions.Question) error {
+	return db.bolt.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketQuestions)
+		for _, question := range qs {
+			if err := put(b, orderKey(question.ID), question); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// ClaimsForOrders retorna as reclamações salvas dos pedidos informados.
+func (db *DB) ClaimsForOrders(orderIDs []int64) ([]claims.Claim, error) {
+	wanted := make(map[int64]bool, len(orderIDs))
+	for _, id := range orderIDs {
+		wanted[id] = true
+	}
+
+	var cs []claims.Claim
+	err := db.bolt.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketClaims).ForEach(func(k, v []byte) error {
+			var claim claims.Claim
+			if err := json.Unmarshal(v, &claim); err != nil {
+				return err
+			}
+			if wanted[claim.OrderID()] {
+				cs = append(cs, claim)
+			}
+			return nil
+		})
+	})
+	return cs, err
+}
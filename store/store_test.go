@@ -0,0 +1,86 @@
+package store
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"dimi/kkalcs/mlapi/orders"
+)
+
+func openTemp(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestMergeKeepsDiscounts(t *testing.T) {
+	db := openTemp(t)
+	created := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+	order := orders.Order{OrderID: 1, Status: "paid", DateCreated: created}
+
+	if err := db.Merge([]orders.Order{order}, created); err != nil {
+		t.Fatal(err)
+	}
+
+	withDiscounts := order
+	withDiscounts.Discounts = &orders.Discounts{Details: []orders.DiscountDetail{{
+		Type:  "coupon",
+		Items: []orders.DiscountItem{{ID: "MLB1", Quantity: 1, Amounts: orders.DiscountAmounts{Total: 10, Seller: 4}}},
+	}}}
+	if err := db.UpsertOrders([]orders.Order{withDiscounts}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Um novo sync traz o pedido de novo, sem os descontos.
+	order.Status = "delivered"
+	if err := db.Merge([]orders.Order{order}, created.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := db.Order(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got == nil {
+		t.Fatal("order not found")
+	}
+	if got.Status != "delivered" {
+		t.Errorf("status: got %s, want delivered", got.Status)
+	}
+	if got.Discounts == nil {
+		t.Fatal("discounts lost on merge")
+	}
+	if seller := got.Discounts.SellerFunded(); seller != 4 {
+		t.Errorf("seller discount: got %v, want 4", seller)
+	}
+}
+
+func TestUpsertOrdersSkipsUndated(t *testing.T) {
+	db := openTemp(t)
+	created := time.Date(2025, time.March, 10, 12, 0, 0, 0, time.UTC)
+	ords := []orders.Order{
+		{OrderID: 1, DateCreated: created},
+		{OrderID: 2},
+		{OrderID: 3, DateCreated: created.Add(time.Hour)},
+	}
+
+	if err := db.UpsertOrders(ords); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got, err := db.Order(2); err != nil || got != nil {
+		t.Errorf("undated order stored: %v, %v", got, err)
+	}
+	between, err := db.OrdersBetween(created, created.Add(2*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(between) != 2 {
+		t.Errorf("got %d orders in the period, want 2", len(between))
+	}
+}
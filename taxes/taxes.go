@@ -0,0 +1,224 @@
+package taxes
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// Regime é o regime tributário da empresa. Rate é a alíquota total sobre a
+// receita e ICMSRate a parte dela que corresponde ao ICMS, que pode ser
+// substituída por SKU.
+type Regime interface {
+	Name() string
+	Rate() float64
+	ICMSRate() float64
+}
+
+// Bracket é uma faixa do Simples Nacional pela receita bruta dos últimos 12 meses.
+type Bracket struct {
+	UpTo      float64 `json:"up_to"`
+	Rate      float64 `json:"rate"`
+	Deduction float64 `json:"deduction"`
+	// ICMSShare é a fração do DAS que corresponde ao ICMS nessa faixa.
+	ICMSShare float64 `json:"icms_share"`
+}
+
+// AnexoI são as faixas do Anexo I (comércio) do Simples Nacional.
+var AnexoI = []Bracket{
+	{UpTo: 180_000, Rate: 0.04, Deduction: 0, ICMSShare: 0.34},
+	{UpTo: 360_000, Rate: 0.073, Deduction: 5_940, ICMSShare: 0.34},
+	{UpTo: 720_000, Rate: 0.095, Deduction: 13_860, ICMSShare: 0.335},
+	{UpTo: 1_800_000, Rate: 0.107, Deduction: 22_500, ICMSShare: 0.335},
+	{UpTo: 3_600_000, Rate: 0.143, Deduction: 87_300, ICMSShare: 0.335},
+	{UpTo: 4_800_000, Rate: 0.19, Deduction: 378_000, ICMSShare: 0},
+}
+
+// SimplesNacional calcula a alíquota efetiva do DAS a partir da receita bruta
+// acumulada em 12 meses (RBT12): (RBT12 × alíquota nominal − dedução) / RBT12.
+type SimplesNacional struct {
+	RBT12    float64   `json:"rbt12"`
+	Brackets []Bracket `json:"brackets"`
+}
+
+func (s SimplesNacional) Name() string {
+	return "simples_nacional"
+}
+
+func (s SimplesNacional) bracket() Bracket {
+	brackets := s.Brackets
+	if len(brackets) == 0 {
+		brackets = AnexoI
+	}
+	for _, b := range brackets {
+		if s.RBT12 <= b.UpTo {
+			return b
+		}
+	}
+	return brackets[len(brackets)-1]
+}
+
+func (s SimplesNacional) Rate() float64 {
+	b := s.bracket()
+	if s.RBT12 <= 0 {
+		return b.Rate
+	}
+	return (s.RBT12*b.Rate - b.Deduction) / s.RBT12
+}
+
+func (s SimplesNacional) ICMSRate() float64 {
+	return s.Rate() * s.bracket().ICMSShare
+}
+
+// LucroPresumido soma as alíquotas efetivas sobre a receita. IRPJ e CSLL já
+// devem vir multiplicados pela presunção (ex.: 8% × 15% = 1,2%).
+type LucroPresumido struct {
+	IRPJ   float64 `json:"irpj"`
+	CSLL   float64 `json:"csll"`
+	PIS    float64 `json:"pis"`
+	COFINS float64 `json:"cofins"`
+	ICMS   float64 `json:"icms"`
+}
+
+// DefaultLucroPresumido usa as presunções de comércio e ICMS interno de 18%.
+func DefaultLucroPresumido() LucroPresumido {
+	return LucroPresumido{
+		IRPJ:   0.08 * 0.15,
+		CSLL:   0.12 * 0.09,
+		PIS:    0.0065,
+		COFINS: 0.03,
+		ICMS:   0.18,
+	}
+}
+
+func (l LucroPresumido) Name() string {
+	return "lucro_presumido"
+}
+
+func (l LucroPresumido) Rate() float64 {
+	return l.IRPJ + l.CSLL + l.PIS + l.COFINS + l.ICMS
+}
+
+func (l LucroPresumido) ICMSRate() float64 {
+	return l.ICMS
+}
+
+// SKURule ajusta o imposto de um SKU. ICMS, se definido, substitui a parte de
+// ICMS do regime (ex.: produtos com substituição tributária). DIFAL é somado.
+type SKURule struct {
+	ICMS  *float64 `json:"icms,omitempty"`
+	DIFAL float64  `json:"difal"`
+}
+
+type Rules struct {
+	Regime Regime
+	SKU    map[string]SKURule
+}
+
+// RateFor é a alíquota total aplicada à receita do SKU.
+func (r Rules) RateFor(sku string) float64 {
+	rate := r.Regime.Rate()
+	rule, ok := r.SKU[sku]
+	if !ok {
+		return rate
+	}
+	if rule.ICMS != nil {
+		rate = rate - r.Regime.ICMSRate() + *rule.ICMS
+	}
+	return rate + rule.DIFAL
+}
+
+type Result struct {
+	Regime        string             `json:"regime"`
+	Revenue       float64            `json:"revenue"`
+	Tax           float64            `json:"tax"`
+	EffectiveRate float64            `json:"effective_rate"`
+	BySKU         map[string]float64 `json:"by_sku"`
+}
+
+// Apply calcula o imposto sobre a receita de cada SKU.
+func (r Rules) Apply(revenueBySKU map[string]float64) Result {
+	result := Result{
+		Regime: r.Regime.Name(),
+		BySKU:  make(map[string]float64),
+	}
+
+	skus := make([]string, 0, len(revenueBySKU))
+	for sku := range revenueBySKU {
+		skus = append(skus, sku)
+	}
+	sort.Strings(skus)
+
+	for _, sku := range skus {
+		revenue := revenueBySKU[sku]
+		tax := revenue * r.RateFor(sku)
+		result.Revenue += revenue
+		result.Tax += tax
+		result.BySKU[sku] = tax
+	}
+	if result.Revenue > 0 {
+		result.EffectiveRate = result.Tax / result.Revenue
+	}
+
+	return result
+}
+
+// RulesFile é o arquivo de regras de impostos lido pelos relatórios de lucro.
+const RulesFile = "tax_rules.json"
+
+// LoadIfExists é como Load, mas retorna nil sem erro se o arquivo não existir.
+func LoadIfExists(path string) (*Rules, error) {
+	if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return Load(path)
+}
+
+// Load lê as regras de um JSON no formato:
+//
+//	{"regime": "simples_nacional", "simples_nacional": {"rbt12": 500000},
+//	 "sku": {"ABC-1": {"icms": 0, "difal": 0.02}}}
+//
+// Para Lucro Presumido, use "regime": "lucro_presumido" e, opcionalmente, as
+// alíquotas em "lucro_presumido"; as que faltarem ficam com o padrão.
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao ler o arquivo: %v", err)
+	}
+
+	var raw struct {
+		Regime          string             `json:"regime"`
+		SimplesNacional *SimplesNacional   `json:"simples_nacional"`
+		LucroPresumido  json.RawMessage    `json:"lucro_presumido"`
+		SKU             map[string]SKURule `json:"sku"`
+	}
+	err = json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, fmt.Errorf("erro ao deserializar regras de impostos: %v", err)
+	}
+
+	rules := &Rules{SKU: raw.SKU}
+	switch raw.Regime {
+	case "simples_nacional":
+		if raw.SimplesNacional == nil {
+			return nil, fmt.Errorf("simples_nacional precisa do rbt12")
+		}
+		rules.Regime = *raw.SimplesNacional
+	case "lucro_presumido":
+		lp := DefaultLucroPresumido()
+		if len(raw.LucroPresumido) > 0 {
+			err = json.Unmarshal(raw.LucroPresumido, &lp)
+			if err != nil {
+				return nil, fmt.Errorf("erro ao deserializar lucro_presumido: %v", err)
+			}
+		}
+		rules.Regime = lp
+	default:
+		return nil, fmt.Errorf("regime tributário desconhecido: %q", raw.Regime)
+	}
+
+	return rules, nil
+}
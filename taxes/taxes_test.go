@@ -0,0 +1,133 @@
+package taxes
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func almostEqual(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSimplesNacionalBrackets(t *testing.T) {
+	tests := []struct {
+		name     string
+		rbt12    float64
+		wantRate float64
+		wantICMS float64
+	}{
+		{"no revenue uses the first bracket", 0, 0.04, 0.04 * 0.34},
+		{"first bracket", 100_000, 0.04, 0.04 * 0.34},
+		{"first bracket upper bound", 180_000, 0.04, 0.04 * 0.34},
+		{"second bracket", 300_000, (300_000*0.073 - 5_940) / 300_000, (300_000*0.073 - 5_940) / 300_000 * 0.34},
+		{"third bracket", 500_000, 0.06728, 0.06728 * 0.335},
+		{"fourth bracket", 1_000_000, 0.0845, 0.0845 * 0.335},
+		{"fifth bracket", 3_600_000, (3_600_000*0.143 - 87_300) / 3_600_000, (3_600_000*0.143 - 87_300) / 3_600_000 * 0.335},
+		{"last bracket has no ICMS share", 4_000_000, 0.0955, 0},
+		{"above the limit stays in the last bracket", 6_000_000, 0.127, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := SimplesNacional{RBT12: tt.rbt12}
+			if got := s.Rate(); !almostEqual(got, tt.wantRate) {
+				t.Errorf("Rate() = %v, want %v", got, tt.wantRate)
+			}
+			if got := s.ICMSRate(); !almostEqual(got, tt.wantICMS) {
+				t.Errorf("ICMSRate() = %v, want %v", got, tt.wantICMS)
+			}
+		})
+	}
+}
+
+func TestRateFor(t *testing.T) {
+	zero := 0.0
+	interstate := 0.12
+	rules := Rules{
+		Regime: SimplesNacional{RBT12: 500_000},
+		SKU: map[string]SKURule{
+			"ST":    {ICMS: &zero},
+			"DIFAL": {DIFAL: 0.02},
+			"BOTH":  {ICMS: &interstate, DIFAL: 0.01},
+		},
+	}
+	base := 0.06728
+	icms := base * 0.335
+
+	tests := []struct {
+		sku  string
+		want float64
+	}{
+		{"OTHER", base},
+		{"ST", base - icms},
+		{"DIFAL", base + 0.02},
+		{"BOTH", base - icms + 0.12 + 0.01},
+	}
+	for _, tt := range tests {
+		if got := rules.RateFor(tt.sku); !almostEqual(got, tt.want) {
+			t.Errorf("RateFor(%s) = %v, want %v", tt.sku, got, tt.want)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	rules := Rules{Regime: LucroPresumido{PIS: 0.01, COFINS: 0.03, ICMS: 0.06}}
+	result := rules.Apply(map[string]float64{"A": 1000, "B": 500})
+
+	if result.Regime != "lucro_presumido" {
+		t.Errorf("Regime = %s", result.Regime)
+	}
+	if !almostEqual(result.Revenue, 1500) || !almostEqual(result.Tax, 150) || !almostEqual(result.EffectiveRate, 0.1) {
+		t.Errorf("got revenue %v, tax %v, rate %v", result.Revenue, result.Tax, result.EffectiveRate)
+	}
+	if !almostEqual(result.BySKU["A"], 100) || !almostEqual(result.BySKU["B"], 50) {
+		t.Errorf("BySKU = %v", result.BySKU)
+	}
+
+	if empty := rules.Apply(nil); empty.EffectiveRate != 0 {
+		t.Errorf("EffectiveRate without revenue = %v", empty.EffectiveRate)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		wantRate float64
+		wantErr  bool
+	}{
+		{"simples nacional", `{"regime": "simples_nacional", "simples_nacional": {"rbt12": 500000}}`, 0.06728, false},
+		{"lucro presumido defaults", `{"regime": "lucro_presumido"}`, DefaultLucroPresumido().Rate(), false},
+		{"lucro presumido override", `{"regime": "lucro_presumido", "lucro_presumido": {"icms": 0.12}}`, DefaultLucroPresumido().Rate() - 0.18 + 0.12, false},
+		{"simples without rbt12", `{"regime": "simples_nacional"}`, 0, true},
+		{"unknown regime", `{"regime": "mei"}`, 0, true},
+		{"invalid json", `{"regime":`, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), RulesFile)
+			if err := os.WriteFile(path, []byte(tt.json), 0644); err != nil {
+				t.Fatal(err)
+			}
+			rules, err := Load(path)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := rules.Regime.Rate(); !almostEqual(got, tt.wantRate) {
+				t.Errorf("Rate() = %v, want %v", got, tt.wantRate)
+			}
+		})
+	}
+
+	rules, err := LoadIfExists(filepath.Join(t.TempDir(), RulesFile))
+	if rules != nil || err != nil {
+		t.Errorf("LoadIfExists without file = %v, %v", rules, err)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"net/url"
 
+	"dimi/kkalcs/mlapi"
 	"dimi/kkalcs/mlapi/requests"
 )
 
@@ -18,7 +19,7 @@ type TestUserResponse struct {
 }
 
 // Função usada para criar usuários de teste.
-func CreateTestUser() (*TestUserResponse, error) {
+func CreateTestUser(c *mlapi.Client) (*TestUserResponse, error) {
 	request_url := "https://api.mercadolibre.com/users/test_user"
 
 	body_request := url.Values{}
@@ -30,7 +31,7 @@ func CreateTestUser() (*TestUserResponse, error) {
 		return nil, err
 	}
 
-	body_response, err := requests.MakeSimpleRequest(requests.POST, request_url, bytes.NewBuffer(body_json))
+	body_response, err := c.MakeSimpleRequest(requests.POST, request_url, bytes.NewBuffer(body_json))
 	if err != nil {
 		return nil, fmt.Errorf("erro ao criar request: %v", err)
 	}
@@ -46,16 +47,16 @@ func CreateTestUser() (*TestUserResponse, error) {
 	return &user, nil
 }
 
-func CreateListings() error {
+func CreateListings(c *mlapi.Client) error {
 	userID := "2408860744"
-	err := createListingForSeller(userID)
+	err := createListingForSeller(c, userID)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-func createListingForSeller(userID string) error {
+func createListingForSeller(c *mlapi.Client, userID string) error {
 	// Criar uma publicação para esse vendedor
 	// O vendedor precisa de pelo menos um produto para ser considerado vendedor ativo
 	// Use a API de publicações para criar o produto
@@ -83,7 +84,7 @@ func createListingForSeller(userID string) error {
 	// Endpoint para criar a publicação
 
 	// Faz a requisição para criar a publicação
-	resp, err := requests.MakeRequest(requests.POST, request_url, bytes.NewBuffer(product_json))
+	resp, err := c.MakeRequest(requests.POST, request_url, bytes.NewBuffer(product_json))
 	if err != nil {
 		fmt.Println("Erro ao criar publicação:", err)
 		return err
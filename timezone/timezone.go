@@ -0,0 +1,55 @@
+// Package timezone define o fuso horário em que as janelas de datas dos
+// pedidos são expressas. O Mercado Livre registra os pedidos no horário local
+// do site (-03:00 no Brasil), então dias e meses precisam ser recortados nesse
+// fuso, e não em UTC.
+package timezone
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+	_ "time/tzdata" // garante o banco de fusos mesmo sem zoneinfo no sistema
+
+	"dimi/kkalcs/dotenv"
+)
+
+// Default é o fuso usado quando TIMEZONE não está definido no .env.
+const Default = "America/Sao_Paulo"
+
+var (
+	once sync.Once
+	loc  *time.Location
+)
+
+// Location retorna o fuso IANA configurado em TIMEZONE, ou America/Sao_Paulo.
+// Um nome inválido gera um aviso e cai no padrão.
+func Location() *time.Location {
+	once.Do(func() {
+		name := dotenv.Get("TIMEZONE")
+		if name == "" {
+			name = Default
+		}
+		l, err := time.LoadLocation(name)
+		if err != nil {
+			slog.Warn("Fuso horário inválido, usando o padrão", "timezone", name, "error", err)
+			l, _ = time.LoadLocation(Default)
+		}
+		loc = l
+	})
+	return loc
+}
+
+// Date é time.Date no fuso configurado.
+func Date(year int, month time.Month, day, hour, min, sec, nsec int) time.Time {
+	return time.Date(year, month, day, hour, min, sec, nsec, Location())
+}
+
+// ParseDate interpreta uma data "2006-01-02" como meia-noite no fuso configurado.
+func ParseDate(s string) (time.Time, error) {
+	return time.ParseInLocation("2006-01-02", s, Location())
+}
+
+// In converte t para o fuso configurado.
+func In(t time.Time) time.Time {
+	return t.In(Location())
+}